@@ -2,10 +2,11 @@ package config
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 var DB *sql.DB
@@ -32,6 +33,9 @@ func InitDB() {
 
 	// Create tables if they don't exist
 	createTables()
+
+	// Apply schema changes to existing tables
+	runMigrations()
 }
 
 // createTables creates necessary database tables
@@ -74,4 +78,10 @@ func createTables() {
 	}
 
 	log.Println("Database tables created successfully")
-}
\ No newline at end of file
+}
+
+// IsDuplicateKey reports whether err is a MySQL unique constraint violation
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
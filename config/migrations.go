@@ -0,0 +1,514 @@
+package config
+
+import (
+	"database/sql"
+	"log"
+)
+
+// migration is a schema change applied once and recorded in schema_migrations.
+// Before, when set, runs ahead of Statements for steps that have to look
+// something up first, such as the name MySQL gave a foreign key.
+type migration struct {
+	ID         string
+	Before     func() error
+	Statements []string
+}
+
+// migrations are applied in order; never edit or reorder an entry once it has shipped
+var migrations = []migration{
+	{
+		ID: "001_order_numbers",
+		Statements: []string{
+			`ALTER TABLE orders ADD COLUMN order_number VARCHAR(32) NULL AFTER order_id`,
+			// Legacy ORD-{user}-{unix} IDs double as their own receipt number
+			`UPDATE orders SET order_number = order_id WHERE order_number IS NULL`,
+			`ALTER TABLE orders MODIFY order_number VARCHAR(32) NOT NULL`,
+			`ALTER TABLE orders MODIFY order_id VARCHAR(32) NOT NULL`,
+			`ALTER TABLE orders ADD UNIQUE INDEX uq_orders_order_id (order_id)`,
+			`ALTER TABLE orders ADD UNIQUE INDEX uq_orders_order_number (order_number)`,
+		},
+	},
+	{
+		ID: "002_coupons",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS coupons (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				code VARCHAR(50) NOT NULL UNIQUE,
+				description VARCHAR(255) NOT NULL DEFAULT '',
+				type ENUM('percentage', 'fixed_amount', 'free_shipping', 'buy_x_get_y') NOT NULL,
+				value DECIMAL(10,2) NOT NULL DEFAULT 0,
+				min_spend DECIMAL(10,2) NOT NULL DEFAULT 0,
+				product_id INT NULL,
+				size_id INT NULL,
+				buy_quantity INT NOT NULL DEFAULT 0,
+				get_quantity INT NOT NULL DEFAULT 0,
+				usage_limit INT NULL,
+				usage_limit_per_user INT NULL,
+				starts_at DATETIME NULL,
+				ends_at DATETIME NULL,
+				is_active BOOLEAN NOT NULL DEFAULT TRUE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS cart_coupons (
+				cart_id INT PRIMARY KEY,
+				coupon_id INT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (cart_id) REFERENCES carts(id) ON DELETE CASCADE,
+				FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS order_discounts (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				order_id INT NOT NULL,
+				coupon_id INT NULL,
+				code VARCHAR(50) NOT NULL,
+				description VARCHAR(255) NOT NULL DEFAULT '',
+				amount DECIMAL(10,2) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+				FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE SET NULL
+			)`,
+			`ALTER TABLE orders ADD COLUMN discount_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER total_amount`,
+		},
+	},
+	{
+		ID: "003_tax",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS tax_classes (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(50) NOT NULL UNIQUE,
+				rate DECIMAL(5,2) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			)`,
+			`INSERT IGNORE INTO tax_classes (name, rate) VALUES ('standard', 7.00), ('zero_rated', 0), ('exempt', 0)`,
+			`ALTER TABLE products ADD COLUMN tax_class_id INT NULL,
+				ADD FOREIGN KEY (tax_class_id) REFERENCES tax_classes(id) ON DELETE SET NULL`,
+			`ALTER TABLE orders ADD COLUMN tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER discount_amount,
+				ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT TRUE AFTER tax_amount`,
+			`ALTER TABLE order_items ADD COLUMN tax_class VARCHAR(50) NULL,
+				ADD COLUMN tax_rate DECIMAL(5,2) NOT NULL DEFAULT 0,
+				ADD COLUMN tax_amount DECIMAL(10,2) NOT NULL DEFAULT 0`,
+			`CREATE TABLE IF NOT EXISTS order_taxes (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				order_id INT NOT NULL,
+				tax_class VARCHAR(50) NOT NULL,
+				rate DECIMAL(5,2) NOT NULL,
+				taxable_amount DECIMAL(10,2) NOT NULL,
+				tax_amount DECIMAL(10,2) NOT NULL,
+				FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		ID: "004_shipping_methods",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS shipping_methods (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				code VARCHAR(50) NOT NULL UNIQUE,
+				name VARCHAR(100) NOT NULL,
+				description VARCHAR(255) NOT NULL DEFAULT '',
+				rate_type ENUM('flat', 'weight') NOT NULL DEFAULT 'flat',
+				free_shipping_threshold DECIMAL(10,2) NULL,
+				is_active BOOLEAN NOT NULL DEFAULT TRUE,
+				display_order INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS shipping_zones (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				country VARCHAR(100) NOT NULL,
+				state VARCHAR(100) NULL,
+				postal_code_prefix VARCHAR(20) NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS shipping_rates (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				method_id INT NOT NULL,
+				zone_id INT NULL,
+				min_weight DECIMAL(10,3) NOT NULL DEFAULT 0,
+				max_weight DECIMAL(10,3) NULL,
+				rate DECIMAL(10,2) NOT NULL,
+				FOREIGN KEY (method_id) REFERENCES shipping_methods(id) ON DELETE CASCADE,
+				FOREIGN KEY (zone_id) REFERENCES shipping_zones(id) ON DELETE CASCADE
+			)`,
+			`ALTER TABLE products ADD COLUMN weight DECIMAL(10,3) NOT NULL DEFAULT 0 AFTER stock`,
+			`ALTER TABLE orders ADD COLUMN shipping_method_id INT NULL AFTER tax_amount,
+				ADD COLUMN shipping_method VARCHAR(100) NULL AFTER shipping_method_id,
+				ADD COLUMN shipping_amount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER shipping_method,
+				ADD FOREIGN KEY (shipping_method_id) REFERENCES shipping_methods(id) ON DELETE SET NULL`,
+		},
+	},
+	{
+		ID: "005_currencies",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS currencies (
+				code CHAR(3) PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				symbol VARCHAR(10) NOT NULL DEFAULT '',
+				exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1,
+				is_base BOOLEAN NOT NULL DEFAULT FALSE,
+				is_active BOOLEAN NOT NULL DEFAULT TRUE,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			)`,
+			// Existing prices are in baht, so THB is the base currency
+			`INSERT INTO currencies (code, name, symbol, exchange_rate, is_base) VALUES ('THB', 'Thai Baht', '฿', 1, TRUE)`,
+			`CREATE TABLE IF NOT EXISTS product_prices (
+				product_id INT NOT NULL,
+				currency CHAR(3) NOT NULL,
+				price DECIMAL(10,2) NOT NULL,
+				PRIMARY KEY (product_id, currency),
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+				FOREIGN KEY (currency) REFERENCES currencies(code) ON DELETE CASCADE
+			)`,
+			`ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'THB' AFTER total_amount,
+				ADD COLUMN exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1 AFTER currency`,
+		},
+	},
+	{
+		ID: "006_guest_carts",
+		Statements: []string{
+			// Guest carts have no user and are found by their token instead
+			`ALTER TABLE carts MODIFY user_id INT NULL`,
+			`ALTER TABLE carts ADD COLUMN token VARCHAR(64) NULL AFTER user_id,
+				ADD UNIQUE INDEX uq_carts_token (token)`,
+		},
+	},
+	{
+		ID: "007_guest_orders",
+		Statements: []string{
+			// Guest orders have no user until the guest registers with the same email
+			`ALTER TABLE orders MODIFY user_id INT NULL`,
+			`ALTER TABLE orders ADD COLUMN guest_email VARCHAR(255) NULL AFTER user_id,
+				ADD INDEX idx_orders_guest_email (guest_email)`,
+		},
+	},
+	{
+		ID: "008_stock_reservations",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS stock_reservations (
+				cart_item_id INT PRIMARY KEY,
+				cart_id INT NOT NULL,
+				product_id INT NOT NULL,
+				size_id INT NULL,
+				quantity INT NOT NULL,
+				expires_at DATETIME NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_stock_reservations_product (product_id, size_id, expires_at),
+				INDEX idx_stock_reservations_cart (cart_id),
+				FOREIGN KEY (cart_item_id) REFERENCES cart_items(id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		ID: "009_cart_reminders",
+		Statements: []string{
+			// No foreign key on cart_id: reminder history outlives purged carts
+			`CREATE TABLE IF NOT EXISTS cart_reminders (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				cart_id INT NOT NULL,
+				email VARCHAR(255) NOT NULL,
+				sent_at DATETIME NOT NULL,
+				recovered_order_id INT NULL,
+				recovered_at DATETIME NULL,
+				INDEX idx_cart_reminders_cart (cart_id, sent_at)
+			)`,
+		},
+	},
+	{
+		ID: "010_saved_items",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS saved_items (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				user_id INT NOT NULL,
+				list VARCHAR(20) NOT NULL,
+				product_id INT NOT NULL,
+				size_id INT NULL,
+				quantity INT NOT NULL DEFAULT 1,
+				price_when_added DECIMAL(10,2) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_saved_items_user (user_id, list),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		// Remember the size ordered so an order can be put back in the cart
+		ID: "011_order_item_sizes",
+		Statements: []string{
+			`ALTER TABLE order_items ADD COLUMN size_id INT NULL AFTER product_id`,
+		},
+	},
+	{
+		ID: "012_categories_collections",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS categories (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				parent_id INT NULL,
+				name VARCHAR(100) NOT NULL,
+				slug VARCHAR(100) NOT NULL UNIQUE,
+				description TEXT,
+				display_order INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				FOREIGN KEY (parent_id) REFERENCES categories(id)
+			)`,
+			`CREATE TABLE IF NOT EXISTS product_categories (
+				product_id INT NOT NULL,
+				category_id INT NOT NULL,
+				PRIMARY KEY (product_id, category_id),
+				INDEX idx_product_categories_category (category_id),
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+				FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS collections (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				slug VARCHAR(100) NOT NULL UNIQUE,
+				description TEXT,
+				is_active BOOLEAN NOT NULL DEFAULT TRUE,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS collection_products (
+				collection_id INT NOT NULL,
+				product_id INT NOT NULL,
+				position INT NOT NULL DEFAULT 0,
+				PRIMARY KEY (collection_id, product_id),
+				FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		ID: "013_product_images",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS product_images (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				product_id INT NOT NULL,
+				storage_key VARCHAR(255) NOT NULL,
+				content_type VARCHAR(50) NOT NULL,
+				width INT NOT NULL,
+				height INT NOT NULL,
+				size_bytes INT NOT NULL,
+				alt_text VARCHAR(255) NOT NULL DEFAULT '',
+				position INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				INDEX idx_product_images_product (product_id, position),
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+		},
+	},
+	{
+		ID: "014_product_variants",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS product_options (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				product_id INT NOT NULL,
+				name VARCHAR(50) NOT NULL,
+				position INT NOT NULL DEFAULT 0,
+				UNIQUE KEY uq_product_options_name (product_id, name),
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS product_option_values (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				option_id INT NOT NULL,
+				value VARCHAR(100) NOT NULL,
+				position INT NOT NULL DEFAULT 0,
+				UNIQUE KEY uq_product_option_values_value (option_id, value),
+				FOREIGN KEY (option_id) REFERENCES product_options(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS product_variants (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				product_id INT NOT NULL,
+				sku VARCHAR(64) NOT NULL UNIQUE,
+				barcode VARCHAR(64) NULL UNIQUE,
+				price DECIMAL(10,2) NULL,
+				stock INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				INDEX idx_product_variants_product (product_id),
+				FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS product_variant_values (
+				variant_id INT NOT NULL,
+				option_value_id INT NOT NULL,
+				PRIMARY KEY (variant_id, option_value_id),
+				FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE,
+				FOREIGN KEY (option_value_id) REFERENCES product_option_values(id) ON DELETE CASCADE
+			)`,
+			// Each product size becomes a variant of a single Size option,
+			// keeping the product_sizes ID so references can be carried over
+			`INSERT INTO product_options (product_id, name, position)
+				SELECT DISTINCT product_id, 'Size', 0 FROM product_sizes`,
+			`INSERT INTO product_option_values (option_id, value, position)
+				SELECT o.id, s.name, s.display_order
+				FROM product_sizes ps
+				JOIN sizes s ON s.id = ps.size_id
+				JOIN product_options o ON o.product_id = ps.product_id AND o.name = 'Size'`,
+			`INSERT INTO product_variants (id, product_id, sku, stock, created_at)
+				SELECT ps.id, ps.product_id, CONCAT('P', ps.product_id, '-S', ps.size_id), ps.stock, ps.created_at
+				FROM product_sizes ps`,
+			`INSERT INTO product_variant_values (variant_id, option_value_id)
+				SELECT ps.id, ov.id
+				FROM product_sizes ps
+				JOIN sizes s ON s.id = ps.size_id
+				JOIN product_options o ON o.product_id = ps.product_id AND o.name = 'Size'
+				JOIN product_option_values ov ON ov.option_id = o.id AND ov.value = s.name`,
+			`ALTER TABLE cart_items ADD COLUMN variant_id INT NULL AFTER product_id,
+				ADD FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE`,
+			`UPDATE cart_items ci
+				JOIN product_sizes ps ON ps.product_id = ci.product_id AND ps.size_id = ci.size_id
+				SET ci.variant_id = ps.id`,
+			`ALTER TABLE stock_reservations ADD COLUMN variant_id INT NULL AFTER product_id,
+				ADD INDEX idx_stock_reservations_variant (product_id, variant_id, expires_at)`,
+			`UPDATE stock_reservations r
+				JOIN product_sizes ps ON ps.product_id = r.product_id AND ps.size_id = r.size_id
+				SET r.variant_id = ps.id`,
+			`ALTER TABLE saved_items ADD COLUMN variant_id INT NULL AFTER product_id,
+				ADD FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE`,
+			`UPDATE saved_items si
+				JOIN product_sizes ps ON ps.product_id = si.product_id AND ps.size_id = si.size_id
+				SET si.variant_id = ps.id`,
+			// Order lines keep the SKU and option values as sold, even if the variant changes later
+			`ALTER TABLE order_items ADD COLUMN variant_id INT NULL AFTER product_id,
+				ADD COLUMN sku VARCHAR(64) NULL AFTER variant_id,
+				ADD COLUMN variant_title VARCHAR(255) NULL AFTER sku`,
+			`UPDATE order_items oi
+				JOIN product_sizes ps ON ps.product_id = oi.product_id AND ps.size_id = oi.size_id
+				JOIN sizes s ON s.id = ps.size_id
+				SET oi.variant_id = ps.id, oi.sku = CONCAT('P', ps.product_id, '-S', ps.size_id), oi.variant_title = s.name`,
+			`ALTER TABLE coupons ADD COLUMN variant_id INT NULL AFTER product_id,
+				ADD FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE`,
+			`UPDATE coupons cp
+				JOIN product_sizes ps ON ps.product_id = cp.product_id AND ps.size_id = cp.size_id
+				SET cp.variant_id = ps.id`,
+			// A size restriction without a product can't be expressed as a
+			// variant; switch those coupons off rather than widen them
+			`UPDATE coupons SET is_active = FALSE WHERE size_id IS NOT NULL AND variant_id IS NULL`,
+		},
+	},
+	{
+		ID: "015_product_archiving",
+		Statements: []string{
+			// Products are archived rather than deleted so orders can still refer to them
+			`ALTER TABLE products ADD COLUMN archived_at TIMESTAMP NULL`,
+			`CREATE INDEX idx_products_archived_at ON products (archived_at)`,
+		},
+	},
+	{
+		ID: "016_product_status",
+		Statements: []string{
+			// Products already in the catalog stay published
+			`ALTER TABLE products
+				ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published',
+				ADD COLUMN publish_at TIMESTAMP NULL,
+				ADD COLUMN unpublish_at TIMESTAMP NULL`,
+			`UPDATE products SET status = 'archived' WHERE archived_at IS NOT NULL`,
+			`ALTER TABLE products ALTER COLUMN status SET DEFAULT 'draft'`,
+			`CREATE INDEX idx_products_status ON products (status)`,
+		},
+	},
+	{
+		ID: "017_product_imports",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS product_imports (
+				id INT AUTO_INCREMENT PRIMARY KEY,
+				format VARCHAR(10) NOT NULL,
+				dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+				status VARCHAR(20) NOT NULL,
+				report TEXT NULL,
+				error TEXT NULL,
+				created_by INT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				finished_at TIMESTAMP NULL,
+				FOREIGN KEY (created_by) REFERENCES users(id)
+			)`,
+		},
+	},
+	{
+		ID:     "018_coupon_variant_set_null",
+		Before: func() error { return dropForeignKey("coupons", "variant_id") },
+		Statements: []string{
+			// Removing a variant keeps its coupons, switched off, instead of
+			// deleting them and the carts' applied codes with them
+			`ALTER TABLE coupons ADD CONSTRAINT fk_coupons_variant
+				FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE SET NULL`,
+		},
+	},
+	{
+		ID: "019_product_stock_from_variants",
+		Statements: []string{
+			// Checkout used to deduct only products.stock, so it could
+			// disagree with the size stock copied into variants by 014
+			`UPDATE products p
+				JOIN (SELECT product_id, SUM(stock) AS stock FROM product_variants GROUP BY product_id) v
+				  ON v.product_id = p.id
+				SET p.stock = v.stock`,
+		},
+	},
+}
+
+// dropForeignKey drops the foreign key on a table's column, whatever name
+// MySQL generated for it
+func dropForeignKey(table, column string) error {
+	var name string
+	err := DB.QueryRow(`
+		SELECT CONSTRAINT_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL`,
+		table, column).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("ALTER TABLE " + table + " DROP FOREIGN KEY " + name)
+	return err
+}
+
+// runMigrations applies any migrations that have not been recorded yet
+func runMigrations() {
+	_, err := DB.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		id VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		log.Fatal("Failed to create schema_migrations table:", err)
+	}
+
+	for _, m := range migrations {
+		var applied bool
+		err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE id = ?)", m.ID).Scan(&applied)
+		if err != nil {
+			log.Fatal("Failed to check migration "+m.ID+":", err)
+		}
+		if applied {
+			continue
+		}
+
+		if m.Before != nil {
+			if err := m.Before(); err != nil {
+				log.Fatal("Failed to apply migration "+m.ID+":", err)
+			}
+		}
+
+		// MySQL commits DDL implicitly, so each statement is applied on its own
+		for _, statement := range m.Statements {
+			if _, err := DB.Exec(statement); err != nil {
+				log.Fatal("Failed to apply migration "+m.ID+":", err)
+			}
+		}
+
+		if _, err := DB.Exec("INSERT INTO schema_migrations (id) VALUES (?)", m.ID); err != nil {
+			log.Fatal("Failed to record migration "+m.ID+":", err)
+		}
+		log.Println("Applied migration", m.ID)
+	}
+}
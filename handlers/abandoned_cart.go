@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAbandonedCartStats reports how many reminders were sent and how many
+// carts checked out afterwards (admin only)
+func GetAbandonedCartStats(c *gin.Context) {
+	var stats struct {
+		RemindersSent    int          `json:"reminders_sent"`
+		CartsRecovered   int          `json:"carts_recovered"`
+		RecoveryRate     float64      `json:"recovery_rate"`     // Recovered orders per hundred reminders
+		RecoveredRevenue models.Money `json:"recovered_revenue"` // In the base currency
+	}
+
+	err := config.DB.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT recovered_order_id),
+		       COALESCE((
+		           SELECT ROUND(SUM(o.total_amount / o.exchange_rate), 2) FROM orders o
+		           WHERE o.status != 'cancelled'
+		             AND o.id IN (SELECT recovered_order_id FROM cart_reminders)), 0)
+		FROM cart_reminders`).Scan(
+		&stats.RemindersSent,
+		&stats.CartsRecovered,
+		&stats.RecoveredRevenue,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch abandoned cart stats"})
+		return
+	}
+
+	if stats.RemindersSent > 0 {
+		stats.RecoveryRate = float64(stats.CartsRecovered) * 100 / float64(stats.RemindersSent)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
@@ -50,9 +50,16 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 	
+	// Guest orders aren't claimed by email here, since the email isn't
+	// verified; they are claimed one at a time with ClaimGuestOrder
+	
+	// Keep anything the visitor put in their cart before registering
+	cartAdjustments := mergeGuestCartOnLogin(c, int(userID))
+	
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "user registered successfully",
 		"user_id": userID,
+		"cart_adjustments": cartAdjustments,
 	})
 }
 
@@ -96,9 +103,13 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 	
+	// Merge the cart the visitor built as a guest
+	cartAdjustments := mergeGuestCartOnLogin(c, user.ID)
+	
 	c.JSON(http.StatusOK, gin.H{
 		"message": "login successful",
 		"token": token,
+		"cart_adjustments": cartAdjustments,
 		"user": gin.H{
 			"id": user.ID,
 			"username": user.Username,
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"database/sql"
-	"fmt"
+	"log"
 	"net/http"
 
 	"goapi/config" //change this to your module
+	"goapi/utils" //change this to your module
 
 	"github.com/gin-gonic/gin"
 )
@@ -65,7 +66,7 @@ func CancelOrder(c *gin.Context) {
     
     // Get order items to restore stock
     rows, err := tx.Query(`
-        SELECT product_id, quantity 
+        SELECT product_id, variant_id, quantity 
         FROM order_items 
         WHERE order_id = ?`, dbOrderID)
     if err != nil {
@@ -77,36 +78,22 @@ func CancelOrder(c *gin.Context) {
     // Restore product stock
     for rows.Next() {
         var productID, quantity int
+        var variantID sql.NullInt64
         
-        err := rows.Scan(&productID, &quantity)
+        err := rows.Scan(&productID, &variantID, &quantity)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order items"})
             return
         }
         
-        // Update product stock
-        _, err = tx.Exec(
-            "UPDATE products SET stock = stock + ? WHERE id = ?",
-            quantity, productID)
+        // Update product and variant stock
+        err = adjustStock(tx, productID, nullIntPtr(variantID), quantity)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product stock"})
             return
         }
     }
     
-    // If a transaction ID exists, cancel the payment
-    if transactionID.Valid {
-        // Create a request to cancel the payment in the Java payment service
-        req, err := http.NewRequest("POST", 
-            fmt.Sprintf("https://cunning-smoothly-aphid.ngrok-free.app/api/payment/cancel/%s", transactionID.String), 
-            nil)
-        
-        if err == nil {
-            client := &http.Client{}
-            _, _ = client.Do(req) // Ignore errors, we've already updated our DB
-        }
-    }
-    
     // Commit transaction
     err = tx.Commit()
     if err != nil {
@@ -114,5 +101,19 @@ func CancelOrder(c *gin.Context) {
         return
     }
     
+    // If a transaction ID exists, cancel the payment
+    if transactionID.Valid {
+        err = utils.Payment.Cancel(c.Request.Context(), transactionID.String)
+        if err != nil {
+            // The order is already cancelled in our DB, so report but don't fail
+            log.Printf("failed to cancel payment %s for order %s: %v", transactionID.String, orderID, err)
+            c.JSON(http.StatusOK, gin.H{
+                "message": "order cancelled successfully",
+                "payment_cancelled": false,
+            })
+            return
+        }
+    }
+    
     c.JSON(http.StatusOK, gin.H{"message": "order cancelled successfully"})
 }
\ No newline at end of file
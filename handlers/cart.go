@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 
 	"net/http"
 	"strconv"
@@ -9,103 +10,136 @@ import (
 
 	"goapi/config"
 	"goapi/models"
+	"goapi/utils"
 	
 	"github.com/gin-gonic/gin"
 )
-// GetCart retrieves the user's current cart
+// GetCart retrieves the current cart of the user or guest
 func GetCart(c *gin.Context) {
-	// Get user ID from context (set by AuthMiddleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+	// Find the cart without creating one; reads don't need a cart row
+	cartID, err := findCart(config.DB, c)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
 	
-	// Find or create cart for user
-	var cartID int
-	err := config.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+	// Show prices in the requested currency
+	currency, err := requestCurrency(config.DB, c)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Create new cart if one doesn't exist
-			result, err := config.DB.Exec("INSERT INTO carts (user_id) VALUES (?)", userID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create cart"})
-				return
-			}
-			
-			cartID64, err := result.LastInsertId()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get cart ID"})
-				return
-			}
-			cartID = int(cartID64)
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-			return
-		}
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+	
+	// No cart yet is an empty cart; one is created when an item is added
+	if cartID == 0 {
+		c.JSON(http.StatusOK, gin.H{"cart": models.CartSummary{Currency: currency.Code}})
+		return
 	}
 	
+	cartSummary, err := loadCartSummary(config.DB, c, cartID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch cart items"})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{"cart": cartSummary})
+}
+
+// loadCartSummary reads a cart's items and totals, priced in the given currency
+func loadCartSummary(q queryer, c *gin.Context, cartID int, currency models.Currency) (models.CartSummary, error) {
 	// Get cart items
-	rows, err := config.DB.Query(`
-		SELECT ci.id, ci.product_id, ci.quantity, 
-		       p.name, p.description, p.price, p.stock 
+	rows, err := q.Query(`
+		SELECT ci.id, ci.product_id, ci.variant_id, COALESCE(v.sku, ''), `+variantTitle+`, ci.quantity, 
+		       p.name, p.description, p.price, pp.price, v.price, COALESCE(v.stock, p.stock), `+taxColumns+`, r.expires_at 
 		FROM cart_items ci 
 		JOIN products p ON ci.product_id = p.id 
-		WHERE ci.cart_id = ?`, cartID)
+		LEFT JOIN product_variants v ON v.id = ci.variant_id 
+		LEFT JOIN tax_classes tc ON tc.id = p.tax_class_id 
+		`+productPriceJoin+` 
+		LEFT JOIN stock_reservations r ON r.cart_item_id = ci.id AND r.expires_at > NOW() 
+		WHERE ci.cart_id = ?`, utils.Tax.DefaultRate, currency.Code, cartID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch cart items"})
-		return
+		return models.CartSummary{}, err
 	}
 	defer rows.Close()
 	
 	var items []models.CartItem
 	var totalItems int
-	var totalAmount float64
+	var totalAmount models.Money
+	taxQuote := &models.CheckoutQuote{}
 	
 	for rows.Next() {
 		var item models.CartItem
 		var product models.Product
+		var line models.QuoteLine
+		var variantID sql.NullInt64
+		var override, variantOverride *models.Money
 		
 		err := rows.Scan(
 			&item.ID, 
 			&item.ProductID, 
+			&variantID,
+			&item.SKU,
+			&item.VariantTitle,
 			&item.Quantity,
 			&product.Name,
 			&product.Description,
 			&product.Price,
+			&override,
+			&variantOverride,
 			&product.Stock,
+			&line.TaxClass,
+			&line.TaxRate,
+			&item.ReservedUntil,
 		)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process cart items"})
-			return
+			return models.CartSummary{}, err
 		}
 		
+		item.VariantID = nullIntPtr(variantID)
 		product.ID = item.ProductID
+		product.Price = variantPrice(product.Price, override, variantOverride, currency)
+		product.Currency = currency.Code
 		item.Product = product
 		item.CartID = cartID
 		
 		items = append(items, item)
 		totalItems += item.Quantity
-		totalAmount += float64(item.Quantity) * product.Price
+		totalAmount += product.Price.Mul(item.Quantity)
+		
+		line.LineTotal = product.Price.Mul(item.Quantity)
+		taxQuote.Lines = append(taxQuote.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return models.CartSummary{}, err
 	}
 	
+	// Work out tax the same way checkout does
+	taxQuote.Subtotal = totalAmount
+	applyTax(taxQuote)
+	
 	// Create cart summary
 	cartSummary := models.CartSummary{
-		CartID:      cartID,
-		ItemCount:   len(items),
-		TotalItems:  totalItems,
-		TotalAmount: totalAmount,
-		Items:       items,
-	}
-	
-	c.JSON(http.StatusOK, gin.H{"cart": cartSummary})
+		CartID:           cartID,
+		CartToken:        cartToken(c),
+		Currency:         currency.Code,
+		ItemCount:        len(items),
+		TotalItems:       totalItems,
+		TotalAmount:      totalAmount,
+		Tax:              taxQuote.Tax,
+		TaxBreakdown:     taxQuote.TaxBreakdown,
+		PricesIncludeTax: taxQuote.PricesIncludeTax,
+		Items:            items,
+	}
+	
+	return cartSummary, nil
 }
 
 // AddToCart adds a product to the cart
 func AddToCart(c *gin.Context) {
 	var input struct {
 		ProductID int `json:"product_id" binding:"required"`
-		SizeID    int `json:"size_id"`
+		VariantID int `json:"variant_id"`
 		Quantity  int `json:"quantity" binding:"required"`
 	}
 	
@@ -121,13 +155,6 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 	
-	// Get user ID from context
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
-		return
-	}
-	
 	// Begin transaction
 	tx, err := config.DB.Begin()
 	if err != nil {
@@ -136,148 +163,174 @@ func AddToCart(c *gin.Context) {
 	}
 	defer tx.Rollback()
 	
-	// Check if product exists
-	var productExists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", input.ProductID).Scan(&productExists)
+	// Add the item, checking stock the same way for every route into the cart
+	if _, err := addCartItem(tx, c, input.ProductID, input.VariantID, input.Quantity); err != nil {
+		writeCartItemError(c, err, "failed to add item to cart")
+		return
+	}
+	
+	// Commit transaction
+	err = tx.Commit()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
 		return
 	}
 	
-	if !productExists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	response := gin.H{"message": "item added to cart successfully"}
+	if token := cartToken(c); token != "" {
+		response["cart_token"] = token
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// cartItemError is a problem with an item being added to the cart, reported with its HTTP status
+type cartItemError struct {
+	status  int
+	message string
+}
+
+func (e *cartItemError) Error() string {
+	return e.message
+}
+
+// writeCartItemError reports cart item problems with their status and anything else as a 500 with fallback
+func writeCartItemError(c *gin.Context, err error, fallback string) {
+	var itemErr *cartItemError
+	if errors.As(err, &itemErr) {
+		c.JSON(itemErr.status, gin.H{"error": itemErr.message})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
+// cartStock returns how much of a product (or one of its variants, if
+// non-zero) a cart can hold, leaving out stock other carts are holding
+func cartStock(q queryer, productID, variantID, cartID int) (int, error) {
+	var stock int
+	var err error
+	if variantID > 0 {
+		err = q.QueryRow("SELECT stock FROM product_variants WHERE id = ? AND product_id = ?", 
+			variantID, productID).Scan(&stock)
+		if err == sql.ErrNoRows {
+			return 0, &cartItemError{http.StatusNotFound, "variant not found for this product"}
+		}
+	} else {
+		// If no variant is specified, check overall product stock
+		err = q.QueryRow("SELECT stock FROM products WHERE id = ?", productID).Scan(&stock)
+	}
+	if err != nil {
+		return 0, err
+	}
 	
-	// Check if the product has sizes
-	var sizeCount int
-	err = tx.QueryRow("SELECT COUNT(*) FROM product_sizes WHERE product_id = ?", input.ProductID).Scan(&sizeCount)
+	// Stock held in other carts isn't available to this one
+	held, err := heldStock(q, productID, variantID, cartID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-		return
+		return 0, err
+	}
+	return stock - held, nil
+}
+
+// addCartItem adds a quantity of a product (and variant, if non-zero) to the
+// request's cart after checking the product, its variant and the stock left
+// once other carts' holds are taken out. It returns the cart item ID.
+func addCartItem(tx *sql.Tx, c *gin.Context, productID, variantID, quantity int) (int, error) {
+	// Check if product exists and is on sale; drafts aren't revealed
+	var status string
+	err := tx.QueryRow("SELECT status FROM products WHERE id = ?", productID).Scan(&status)
+	if err == sql.ErrNoRows || (err == nil && status != models.ProductPublished && status != models.ProductArchived) {
+		return 0, &cartItemError{http.StatusNotFound, "product not found"}
+	}
+	if err != nil {
+		return 0, err
 	}
 	
-	// If product has sizes, a size ID is required
-	if sizeCount > 0 && input.SizeID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "size is required for this product"})
-		return
+	if status == models.ProductArchived {
+		return 0, &cartItemError{http.StatusBadRequest, "product is no longer available"}
 	}
 	
-	// Check if size exists and has enough stock (if a size is specified)
-	var stockAvailable int
-	if input.SizeID > 0 {
-		err = tx.QueryRow("SELECT stock FROM product_sizes WHERE product_id = ? AND size_id = ?", 
-			input.ProductID, input.SizeID).Scan(&stockAvailable)
+	if err := lockProductStock(tx, productID); err != nil {
+		return 0, err
+	}
+	
+	// A product with variants is sold by variant; one with a single variant
+	// doesn't need it picked
+	if variantID == 0 {
+		var variantCount, onlyVariantID int
+		err = tx.QueryRow("SELECT COUNT(*), COALESCE(MIN(id), 0) FROM product_variants WHERE product_id = ?", 
+			productID).Scan(&variantCount, &onlyVariantID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "size not found for this product"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-			}
-			return
+			return 0, err
 		}
-	} else {
-		// If no size is specified, check overall product stock
-		err = tx.QueryRow("SELECT stock FROM products WHERE id = ?", input.ProductID).Scan(&stockAvailable)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-			return
+		
+		switch {
+		case variantCount == 1:
+			variantID = onlyVariantID
+		case variantCount > 1:
+			return 0, &cartItemError{http.StatusBadRequest, "variant is required for this product"}
 		}
 	}
 	
-	if stockAvailable < input.Quantity {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "not enough stock available"})
-		return
+	// Find or create cart for the user or guest
+	cartID, err := findOrCreateCart(tx, c)
+	if err != nil {
+		return 0, err
 	}
 	
-	// Find or create cart for user
-	var cartID int
-	err = tx.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+	// Check the variant exists and how much stock this cart can take
+	stockAvailable, err := cartStock(tx, productID, variantID, cartID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Create new cart if one doesn't exist
-			result, err := tx.Exec("INSERT INTO carts (user_id) VALUES (?)", userID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create cart"})
-				return
-			}
-			
-			cartIDInt64, err := result.LastInsertId()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get cart ID"})
-				return
-			}
-			cartID = int(cartIDInt64)
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-			return
-		}
+		return 0, err
 	}
 	
-	// Check if item already exists in cart (including size)
-	var existingItemID int
-	var existingQuantity int
-	var findItemQuery string
-	var findItemArgs []interface{}
+	if stockAvailable < quantity {
+		return 0, &cartItemError{http.StatusBadRequest, "not enough stock available"}
+	}
 	
-	if input.SizeID > 0 {
-		findItemQuery = "SELECT id, quantity FROM cart_items WHERE cart_id = ? AND product_id = ? AND size_id = ?"
-		findItemArgs = []interface{}{cartID, input.ProductID, input.SizeID}
-	} else {
-		findItemQuery = "SELECT id, quantity FROM cart_items WHERE cart_id = ? AND product_id = ? AND size_id IS NULL"
-		findItemArgs = []interface{}{cartID, input.ProductID}
+	var variant interface{}
+	if variantID > 0 {
+		variant = variantID
 	}
 	
-	err = tx.QueryRow(findItemQuery, findItemArgs...).Scan(&existingItemID, &existingQuantity)
+	// Check if item already exists in cart (including variant)
+	var itemID int
+	var existingQuantity int
+	err = tx.QueryRow("SELECT id, quantity FROM cart_items WHERE cart_id = ? AND product_id = ? AND variant_id <=> ?", 
+		cartID, productID, variant).Scan(&itemID, &existingQuantity)
 	
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Add new item to cart
-			var insertQuery string
-			var insertArgs []interface{}
-			
-			if input.SizeID > 0 {
-				insertQuery = "INSERT INTO cart_items (cart_id, product_id, size_id, quantity) VALUES (?, ?, ?, ?)"
-				insertArgs = []interface{}{cartID, input.ProductID, input.SizeID, input.Quantity}
-			} else {
-				insertQuery = "INSERT INTO cart_items (cart_id, product_id, quantity) VALUES (?, ?, ?)"
-				insertArgs = []interface{}{cartID, input.ProductID, input.Quantity}
-			}
-			
-			_, err = tx.Exec(insertQuery, insertArgs...)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add item to cart"})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-			return
+	if err == sql.ErrNoRows {
+		// Add new item to cart
+		result, err := tx.Exec("INSERT INTO cart_items (cart_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)", 
+			cartID, productID, variant, quantity)
+		if err != nil {
+			return 0, err
 		}
+		
+		newItemID, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		itemID = int(newItemID)
+	} else if err != nil {
+		return 0, err
 	} else {
 		// Update existing item quantity
-		newQuantity := existingQuantity + input.Quantity
+		newQuantity := existingQuantity + quantity
 		if newQuantity > stockAvailable {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "not enough stock available"})
-			return
+			return 0, &cartItemError{http.StatusBadRequest, "not enough stock available"}
 		}
 		
-		_, err = tx.Exec(
-			"UPDATE cart_items SET quantity = ? WHERE id = ?",
-			newQuantity, existingItemID)
+		_, err = tx.Exec("UPDATE cart_items SET quantity = ? WHERE id = ?", newQuantity, itemID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart item"})
-			return
+			return 0, err
 		}
 	}
 	
-	// Commit transaction
-	err = tx.Commit()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
-		return
+	// Hold the stock for this cart
+	if err := reserveCartItem(tx, itemID); err != nil {
+		return 0, err
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"message": "item added to cart successfully"})
+	return itemID, nil
 }
 
 // UpdateCartItem updates the quantity of a cart item
@@ -299,75 +352,86 @@ func UpdateCartItem(c *gin.Context) {
 		return
 	}
 	
-	// Validate quantity
-	if input.Quantity < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity cannot be negative"})
+	// Begin transaction so the stock lock is held until the update is saved
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
 		return
 	}
+	defer tx.Rollback()
 	
-	// Get user ID from context
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+	// Verify the item is in the cart of this user or guest
+	cartID, err := findCart(tx, c)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
 	
-	// Verify user owns the cart containing this item
-	var count int
-	err = config.DB.QueryRow(`
-		SELECT COUNT(*) FROM cart_items ci
-		JOIN carts c ON ci.cart_id = c.id
-		WHERE ci.id = ? AND c.user_id = ?`, 
-		itemID, userID).Scan(&count)
-	
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+	// Update or remove item based on quantity
+	if err := setCartItemQuantity(tx, cartID, itemID, input.Quantity); err != nil {
+		writeCartItemError(c, err, "failed to update cart item")
 		return
 	}
 	
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found or not authorized"})
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
 		return
 	}
 	
-	// Get product ID and check stock
+	if input.Quantity == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "item removed from cart"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "cart item updated successfully"})
+	}
+}
+
+// setCartItemQuantity changes the quantity of an item in the cart, checking
+// the product's (or variant's) stock left once other carts' holds are taken
+// out. A quantity of zero removes the item.
+func setCartItemQuantity(q queryer, cartID, itemID, quantity int) error {
+	// Validate quantity
+	if quantity < 0 {
+		return &cartItemError{http.StatusBadRequest, "quantity cannot be negative"}
+	}
+	
+	// Get product and variant, making sure the item is in this cart
 	var productID int
-	err = config.DB.QueryRow("SELECT product_id FROM cart_items WHERE id = ?", itemID).Scan(&productID)
+	var variantID sql.NullInt64
+	err := q.QueryRow("SELECT product_id, variant_id FROM cart_items WHERE id = ? AND cart_id = ?", 
+		itemID, cartID).Scan(&productID, &variantID)
+	if err == sql.ErrNoRows {
+		return &cartItemError{http.StatusNotFound, "cart item not found or not authorized"}
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-		return
+		return err
+	}
+	
+	if quantity == 0 {
+		// Remove item from cart
+		_, err = q.Exec("DELETE FROM cart_items WHERE id = ?", itemID)
+		return err
+	}
+	
+	if err := lockProductStock(q, productID); err != nil {
+		return err
 	}
 	
-	var productStock int
-	err = config.DB.QueryRow("SELECT stock FROM products WHERE id = ?", productID).Scan(&productStock)
+	available, err := cartStock(q, productID, int(variantID.Int64), cartID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-		return
+		return err
 	}
 	
-	if input.Quantity > productStock {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "not enough stock available"})
-		return
+	if quantity > available {
+		return &cartItemError{http.StatusBadRequest, "not enough stock available"}
 	}
 	
-	// Update or remove item based on quantity
-	if input.Quantity == 0 {
-		// Remove item from cart
-		_, err = config.DB.Exec("DELETE FROM cart_items WHERE id = ?", itemID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove item from cart"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "item removed from cart"})
-	} else {
-		// Update quantity
-		_, err = config.DB.Exec("UPDATE cart_items SET quantity = ? WHERE id = ?", input.Quantity, itemID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart item"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "cart item updated successfully"})
+	// Update quantity
+	_, err = q.Exec("UPDATE cart_items SET quantity = ? WHERE id = ?", quantity, itemID)
+	if err != nil {
+		return err
 	}
+	return reserveCartItem(q, itemID)
 }
 
 // RemoveFromCart removes an item from the cart
@@ -379,53 +443,44 @@ func RemoveFromCart(c *gin.Context) {
 		return
 	}
 	
-	// Get user ID from context
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+	// Verify the item is in the cart of this user or guest
+	cartID, err := findCart(config.DB, c)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
 		return
 	}
 	
-	// Verify user owns the cart containing this item
-	var count int
-	err = config.DB.QueryRow(`
-		SELECT COUNT(*) FROM cart_items ci
-		JOIN carts c ON ci.cart_id = c.id
-		WHERE ci.id = ? AND c.user_id = ?`, 
-		itemID, userID).Scan(&count)
-	
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+	// Remove item from cart
+	if err := removeCartItem(config.DB, cartID, itemID); err != nil {
+		writeCartItemError(c, err, "failed to remove item from cart")
 		return
 	}
 	
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found or not authorized"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": "item removed from cart successfully"})
+}
+
+// removeCartItem deletes an item from the cart, releasing its stock hold
+func removeCartItem(q queryer, cartID, itemID int) error {
+	result, err := q.Exec("DELETE FROM cart_items WHERE id = ? AND cart_id = ?", itemID, cartID)
+	if err != nil {
+		return err
 	}
 	
-	// Remove item from cart
-	_, err = config.DB.Exec("DELETE FROM cart_items WHERE id = ?", itemID)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove item from cart"})
-		return
+		return err
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"message": "item removed from cart successfully"})
+	if rowsAffected == 0 {
+		return &cartItemError{http.StatusNotFound, "cart item not found or not authorized"}
+	}
+	return nil
 }
 
-// ClearCart removes all items from the user's cart
+// ClearCart removes all items from the cart of the user or guest
 func ClearCart(c *gin.Context) {
-	// Get user ID from context
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
-		return
-	}
-	
 	// Get cart ID
-	var cartID int
-	err := config.DB.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+	cartID, err := findCart(config.DB, c)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// No cart exists, so it's already "cleared"
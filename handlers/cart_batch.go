@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PatchCart applies a batch of add, update and remove operations to the cart
+// in one transaction, with the same checks as the single-item endpoints.
+// Either every operation is applied or, when one fails, none are; the
+// results say which operation failed and why.
+func PatchCart(c *gin.Context) {
+	var input models.CartBatchInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The summary is priced in the requested currency
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Find or create cart for the user or guest
+	cartID, err := findOrCreateCart(tx, c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create cart"})
+		return
+	}
+
+	results := make([]models.CartOperationResult, len(input.Operations))
+	for i, op := range input.Operations {
+		results[i] = models.CartOperationResult{Index: i, Op: op.Op, Status: models.CartOpNotApplied}
+	}
+
+	itemIDs := make([]int, len(input.Operations))
+	for i, op := range input.Operations {
+		itemID := op.ItemID
+
+		switch op.Op {
+		case models.CartOpAdd:
+			if op.Quantity < 1 {
+				err = &cartItemError{http.StatusBadRequest, "quantity must be at least 1"}
+			} else {
+				itemID, err = addCartItem(tx, c, op.ProductID, op.VariantID, op.Quantity)
+			}
+		case models.CartOpUpdate:
+			err = setCartItemQuantity(tx, cartID, op.ItemID, op.Quantity)
+		case models.CartOpRemove:
+			err = removeCartItem(tx, cartID, op.ItemID)
+		}
+
+		if err != nil {
+			var itemErr *cartItemError
+			if !errors.As(err, &itemErr) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart"})
+				return
+			}
+
+			// The batch is rolled back, so the other operations stay not applied
+			results[i].ItemID = op.ItemID
+			results[i].Status = models.CartOpFailed
+			results[i].Error = itemErr.message
+			c.JSON(itemErr.status, gin.H{
+				"error":   fmt.Sprintf("operation %d failed: %s", i, itemErr.message),
+				"results": results,
+			})
+			return
+		}
+		itemIDs[i] = itemID
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	for i := range results {
+		results[i].ItemID = itemIDs[i]
+		results[i].Status = models.CartOpOK
+	}
+
+	cartSummary, err := loadCartSummary(config.DB, c, cartID, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch cart items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"cart":    cartSummary,
+	})
+}
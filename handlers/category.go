@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const categoryColumns = `id, parent_id, name, slug, COALESCE(description, ''), display_order, created_at, updated_at`
+
+// categoryTreeQuery selects the IDs of the categories with the given slugs and
+// all their subcategories. Fill in the slug placeholders with fmt.Sprintf.
+const categoryTreeQuery = `
+	WITH RECURSIVE category_tree AS (
+		SELECT id FROM categories WHERE slug IN (%s)
+		UNION ALL
+		SELECT c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+	)
+	SELECT id FROM category_tree`
+
+// scanCategory reads a row selected with categoryColumns
+func scanCategory(row scanner) (models.Category, error) {
+	var category models.Category
+	var parentID sql.NullInt64
+
+	err := row.Scan(
+		&category.ID,
+		&parentID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.DisplayOrder,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	category.ParentID = nullIntPtr(parentID)
+	return category, err
+}
+
+// slugify makes a URL slug from a name: lowercase letters and digits joined by dashes
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// loadCategories reads every category, keyed by ID
+func loadCategories(q queryer) (map[int]models.Category, error) {
+	rows, err := q.Query(`SELECT ` + categoryColumns + ` FROM categories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[int]models.Category)
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories[category.ID] = category
+	}
+	return categories, rows.Err()
+}
+
+// categoryChildren builds the subtree under a parent (nil for the roots),
+// ordered by display order then name
+func categoryChildren(categories map[int]models.Category, parentID *int) []models.Category {
+	children := []models.Category{}
+	for _, category := range categories {
+		if (parentID == nil && category.ParentID == nil) ||
+			(parentID != nil && category.ParentID != nil && *category.ParentID == *parentID) {
+			id := category.ID
+			category.Children = categoryChildren(categories, &id)
+			children = append(children, category)
+		}
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].DisplayOrder != children[j].DisplayOrder {
+			return children[i].DisplayOrder < children[j].DisplayOrder
+		}
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// categoryPath returns a category and its ancestors, root first
+func categoryPath(categories map[int]models.Category, categoryID int) []models.CategoryRef {
+	var path []models.CategoryRef
+	for seen := 0; seen <= len(categories); seen++ {
+		category, ok := categories[categoryID]
+		if !ok {
+			break
+		}
+		path = append([]models.CategoryRef{{ID: category.ID, Name: category.Name, Slug: category.Slug}}, path...)
+		if category.ParentID == nil {
+			break
+		}
+		categoryID = *category.ParentID
+	}
+	return path
+}
+
+// loadProductCategories reads the categories of a set of products, keyed by product ID
+func loadProductCategories(q queryer, productIDs ...int) (map[int][]models.CategoryRef, error) {
+	categories := make(map[int][]models.CategoryRef)
+	if len(productIDs) == 0 {
+		return categories, nil
+	}
+
+	placeholders, args := idPlaceholders(productIDs)
+	rows, err := q.Query(`
+		SELECT pc.product_id, c.id, c.name, c.slug
+		FROM product_categories pc
+		JOIN categories c ON c.id = pc.category_id
+		WHERE pc.product_id IN (`+placeholders+`)
+		ORDER BY c.display_order, c.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID int
+		var category models.CategoryRef
+		if err := rows.Scan(&productID, &category.ID, &category.Name, &category.Slug); err != nil {
+			return nil, err
+		}
+		categories[productID] = append(categories[productID], category)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategories returns the category tree
+func GetCategories(c *gin.Context) {
+	categories, err := loadCategories(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categoryChildren(categories, nil)})
+}
+
+// GetCategory returns a category by slug with its subcategories and the path from the root
+func GetCategory(c *gin.Context) {
+	categories, err := loadCategories(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+		return
+	}
+
+	for _, category := range categories {
+		if category.Slug != c.Param("slug") {
+			continue
+		}
+
+		id := category.ID
+		category.Children = categoryChildren(categories, &id)
+		c.JSON(http.StatusOK, gin.H{
+			"category":    category,
+			"breadcrumbs": categoryPath(categories, category.ID),
+		})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+}
+
+// validateCategoryInput fills in the slug and checks the parent, returning an
+// error message or "". A category can't be moved under itself or its own
+// subcategories.
+func validateCategoryInput(q queryer, input *models.CategoryInput, categoryID int) (string, error) {
+	if input.Slug == "" {
+		input.Slug = slugify(input.Name)
+	} else {
+		input.Slug = slugify(input.Slug)
+	}
+	if input.Slug == "" {
+		return "slug is required", nil
+	}
+
+	if input.ParentID == nil {
+		return "", nil
+	}
+
+	categories, err := loadCategories(q)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := categories[*input.ParentID]; !ok {
+		return "parent category not found", nil
+	}
+	for _, ancestor := range categoryPath(categories, *input.ParentID) {
+		if ancestor.ID == categoryID {
+			return "a category can't be moved under itself", nil
+		}
+	}
+	return "", nil
+}
+
+// CreateCategory adds a category (admin only)
+func CreateCategory(c *gin.Context) {
+	var input models.CategoryInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg, err := validateCategoryInput(config.DB, &input, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO categories (parent_id, name, slug, description, display_order)
+		VALUES (?, ?, ?, ?, ?)`,
+		input.ParentID, input.Name, input.Slug, input.Description, input.DisplayOrder)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category slug already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category"})
+		return
+	}
+
+	// Get category ID
+	categoryID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get category ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "category created successfully",
+		"category_id": categoryID,
+		"slug":        input.Slug,
+	})
+}
+
+// UpdateCategory updates a category, including moving it to another parent (admin only)
+func UpdateCategory(c *gin.Context) {
+	// Get category ID from URL
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var input models.CategoryInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the whole tree so two moves can't each pass the cycle check
+	// against the tree as it was before the other
+	rows, err := tx.Query("SELECT id FROM categories FOR UPDATE")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	found := false
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		found = found || id == categoryID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	msg, err := validateCategoryInput(tx, &input, categoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE categories SET parent_id = ?, name = ?, slug = ?, description = ?, display_order = ?
+		WHERE id = ?`,
+		input.ParentID, input.Name, input.Slug, input.Description, input.DisplayOrder, categoryID)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category slug already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category"})
+		return
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	// Search facets use category slugs and include parent categories
+	syncSearchIndexAll()
+
+	c.JSON(http.StatusOK, gin.H{"message": "category updated successfully"})
+}
+
+// DeleteCategory removes a category that has no subcategories (admin only).
+// Its products stay in the catalog.
+func DeleteCategory(c *gin.Context) {
+	// Get category ID from URL
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+		return
+	}
+
+	var children int
+	err = config.DB.QueryRow("SELECT COUNT(*) FROM categories WHERE parent_id = ?", categoryID).Scan(&children)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if children > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category has subcategories; move or delete them first"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	syncSearchIndexAll()
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
+}
+
+// UpdateProductCategories replaces the categories a product belongs to (admin only)
+func UpdateProductCategories(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var input models.ProductCategoriesInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Check if product exists
+	var productExists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&productExists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !productExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM product_categories WHERE product_id = ?", productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product categories"})
+		return
+	}
+
+	for _, categoryID := range input.CategoryIDs {
+		var categoryExists bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)", categoryID).Scan(&categoryExists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		if !categoryExists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category " + strconv.Itoa(categoryID) + " not found"})
+			return
+		}
+
+		_, err = tx.Exec("INSERT IGNORE INTO product_categories (product_id, category_id) VALUES (?, ?)", productID, categoryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product categories"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	syncSearchIndex(productID)
+
+	categories, err := loadProductCategories(config.DB, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "product categories updated successfully",
+		"categories": categories[productID],
+	})
+}
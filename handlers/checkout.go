@@ -1,38 +1,44 @@
 package handlers
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/mail"
 	"strings"
-	"time"
 
 	"goapi/config"
 	"goapi/models"
+	"goapi/utils"
 	
 	"github.com/gin-gonic/gin"
 )
 
-// Checkout converts a cart to an order and initiates payment
+// Checkout converts a cart to an order and initiates payment. Guests check
+// out their token cart with an email and an inline shipping address.
 func Checkout(c *gin.Context) {
     // Parse the request
-    var input struct {
-        ShippingAddressID *int `json:"shipping_address_id"`
-        ShippingAddress   *models.ShippingAddressInput `json:"shipping_address"`
-    }
+    var input models.CheckoutInput
     
     if err := c.ShouldBindJSON(&input); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
     
-    // Get user ID from context
-    userID, exists := c.Get("userID")
-    if !exists {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+    // Identify the buyer: a signed-in user or a guest with an email
+    buyer, err := checkoutCustomer(c, input, true)
+    if err != nil {
+        writeCheckoutError(c, err, "invalid checkout input")
+        return
+    }
+    userID := buyer.userID
+    
+    // Resolve the currency the order is charged in
+    currency, err := requestCurrency(config.DB, c)
+    if err != nil {
+        writeCheckoutError(c, err, "failed to load currency")
         return
     }
     
@@ -45,42 +51,15 @@ func Checkout(c *gin.Context) {
     defer tx.Rollback() // Will be ignored if transaction is committed
     
     // Get shipping address information
+    address, err := resolveCheckoutAddress(tx, input, userID)
+    if err != nil {
+        writeCheckoutError(c, err, "database error")
+        return
+    }
+    
     var shippingAddressJSON string
     
     if input.ShippingAddressID != nil {
-        // Verify the address exists and belongs to the user
-        var address models.ShippingAddress
-        var addressLine2 sql.NullString
-        
-        err := tx.QueryRow(`
-            SELECT id, recipient_name, phone, address_line1, address_line2, city, state, postal_code, country 
-            FROM shipping_addresses 
-            WHERE id = ? AND user_id = ?`, 
-            *input.ShippingAddressID, userID).Scan(
-                &address.ID,
-                &address.RecipientName,
-                &address.Phone,
-                &address.AddressLine1,
-                &addressLine2,
-                &address.City,
-                &address.State,
-                &address.PostalCode,
-                &address.Country,
-            )
-        
-        if err != nil {
-            if err == sql.ErrNoRows {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "shipping address not found"})
-            } else {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-            }
-            return
-        }
-        
-        if addressLine2.Valid {
-            address.AddressLine2 = addressLine2.String
-        }
-        
         // Convert address to JSON
         addressBytes, err := json.Marshal(address)
         if err != nil {
@@ -89,7 +68,7 @@ func Checkout(c *gin.Context) {
         }
         
         shippingAddressJSON = string(addressBytes)
-    } else if input.ShippingAddress != nil {
+    } else {
         // Use the provided address
         addressBytes, err := json.Marshal(input.ShippingAddress)
         if err != nil {
@@ -98,8 +77,11 @@ func Checkout(c *gin.Context) {
         }
         
         shippingAddressJSON = string(addressBytes)
-        
-        // Optionally save this address to the user's saved addresses
+    }
+    
+    // Save an inline address to a signed-in user's saved addresses
+    if input.ShippingAddressID == nil && userID != nil {
+        // Optionally make it the default address
         if input.ShippingAddress.IsDefault {
             // If this will be the default address, unset any existing default
             _, err = tx.Exec(
@@ -127,14 +109,10 @@ func Checkout(c *gin.Context) {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save shipping address"})
             return
         }
-    } else {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "shipping address information is required"})
-        return
     }
     
     // Get cart and verify it has items
-    var cartID int
-    err = tx.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+    cartID, err := findCart(tx, c)
     if err != nil {
         if err == sql.ErrNoRows {
             c.JSON(http.StatusBadRequest, gin.H{"error": "no active cart found"})
@@ -144,101 +122,56 @@ func Checkout(c *gin.Context) {
         return
     }
     
-    // Check if cart has items
-    var itemCount int
-    err = tx.QueryRow("SELECT COUNT(*) FROM cart_items WHERE cart_id = ?", cartID).Scan(&itemCount)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
-        return
-    }
-    
-    if itemCount == 0 {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
-        return
-    }
-    
     // Get user info for payment
     var user models.User
-    err = tx.QueryRow("SELECT username, email FROM users WHERE id = ?", userID).Scan(
-        &user.Username, &user.Email,
-    )
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user information"})
-        return
+    if userID != nil {
+        err = tx.QueryRow("SELECT username, email FROM users WHERE id = ?", userID).Scan(
+            &user.Username, &user.Email,
+        )
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user information"})
+            return
+        }
+    } else {
+        user.Username = address.RecipientName
+        user.Email = buyer.email
     }
     
-    // Get cart items and calculate total
-    rows, err := tx.Query(`
-        SELECT ci.product_id, ci.quantity, p.name, p.price, p.stock
-        FROM cart_items ci 
-        JOIN products p ON ci.product_id = p.id 
-        WHERE ci.cart_id = ?`, cartID)
+    // Price the cart exactly as the quote endpoint does
+    quote, err := priceCart(tx, cartID, buyer, address, input.ShippingMethodID, currency)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch cart items"})
+        writeCheckoutError(c, err, "failed to fetch cart items")
         return
     }
-    defer rows.Close()
-    
-    var cartItems []struct {
-        ProductID   int     `json:"product_id"`
-        Quantity    int     `json:"quantity"`
-        Name        string  `json:"name"`
-        Price       float64 `json:"price"`
-        CurrentStock int    `json:"current_stock"`
-    }
+    totalAmount := quote.GrandTotal
     
-    var totalAmount float64
     var orderDescription strings.Builder
-    
-    for rows.Next() {
-        var item struct {
-            ProductID   int     `json:"product_id"`
-            Quantity    int     `json:"quantity"`
-            Name        string  `json:"name"`
-            Price       float64 `json:"price"`
-            CurrentStock int    `json:"current_stock"`
-        }
-        
-        err := rows.Scan(
-            &item.ProductID,
-            &item.Quantity,
-            &item.Name,
-            &item.Price,
-            &item.CurrentStock,
-        )
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process cart items"})
-            return
-        }
-        
-        // Check stock availability again
-        if item.Quantity > item.CurrentStock {
-            c.JSON(http.StatusBadRequest, gin.H{
-                "error": fmt.Sprintf("Not enough stock for %s. Available: %d, Requested: %d", 
-                    item.Name, item.CurrentStock, item.Quantity),
-            })
-            return
-        }
-        
-        cartItems = append(cartItems, item)
-        itemTotal := float64(item.Quantity) * item.Price
-        totalAmount += itemTotal
-        
+    for _, line := range quote.Lines {
         if orderDescription.Len() > 0 {
             orderDescription.WriteString(", ")
         }
-        orderDescription.WriteString(fmt.Sprintf("%s x%d", item.Name, item.Quantity))
+        orderDescription.WriteString(fmt.Sprintf("%s x%d", line.Name, line.Quantity))
     }
     
-    // Generate unique order ID
-    orderID := fmt.Sprintf("ORD-%d-%d", userID, time.Now().Unix())
+    // Generate order identifiers: a ULID for APIs and a short number for receipts
+    orderID := utils.NewOrderID()
+    var orderNumber string
+    var orderResult sql.Result
     
     // Insert order into database with shipping address
-    orderResult, err := tx.Exec(`
-        INSERT INTO orders (
-            order_id, user_id, total_amount, status, shipping_address, created_at, updated_at
-        ) VALUES (?, ?, ?, 'pending', ?, NOW(), NOW())`,
-        orderID, userID, totalAmount, shippingAddressJSON)
+    for attempt := 0; attempt < 3; attempt++ {
+        orderNumber = utils.NewOrderNumber()
+        orderResult, err = tx.Exec(`
+            INSERT INTO orders (
+                order_id, order_number, user_id, guest_email, total_amount, currency, exchange_rate, discount_amount, tax_amount, prices_include_tax,
+                shipping_method_id, shipping_method, shipping_amount, status, shipping_address, created_at, updated_at
+            ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', ?, NOW(), NOW())`,
+            orderID, orderNumber, userID, nullIfEmpty(buyer.email), totalAmount, quote.Currency, quote.ExchangeRate, quote.Discount, quote.Tax, quote.PricesIncludeTax,
+            quote.ShippingMethodID, quote.ShippingMethod, quote.Shipping, shippingAddressJSON)
+        if !config.IsDuplicateKey(err) {
+            break
+        }
+    }
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create order"})
         return
@@ -251,33 +184,83 @@ func Checkout(c *gin.Context) {
     }
     
     // Insert order items
-    for _, item := range cartItems {
+    for _, item := range quote.Lines {
         _, err = tx.Exec(`
-            INSERT INTO order_items (order_id, product_id, quantity, price)
-            VALUES (?, ?, ?, ?)`,
-            dbOrderID, item.ProductID, item.Quantity, item.Price)
+            INSERT INTO order_items (order_id, product_id, variant_id, sku, variant_title, quantity, price, tax_class, tax_rate, tax_amount)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+            dbOrderID, item.ProductID, item.VariantID, nullIfEmpty(item.SKU), nullIfEmpty(item.VariantTitle),
+            item.Quantity, item.UnitPrice, item.TaxClass, item.TaxRate, item.TaxAmount)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create order items"})
             return
         }
         
-        // Update product stock
-        _, err = tx.Exec(
-            "UPDATE products SET stock = stock - ? WHERE id = ?",
-            item.Quantity, item.ProductID)
+        // Update product and variant stock
+        err = adjustStock(tx, item.ProductID, item.VariantID, -item.Quantity)
+        if err == errOutOfStock {
+            c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s just sold out", item.Name)})
+            return
+        }
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product stock"})
             return
         }
     }
     
-    // Clear the cart
+    // Record the tax breakdown
+    for _, tax := range quote.TaxBreakdown {
+        _, err = tx.Exec(`
+            INSERT INTO order_taxes (order_id, tax_class, rate, taxable_amount, tax_amount)
+            VALUES (?, ?, ?, ?, ?)`,
+            dbOrderID, tax.TaxClass, tax.Rate, tax.TaxableAmount, tax.TaxAmount)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record order taxes"})
+            return
+        }
+    }
+    
+    // Record applied discounts
+    for _, discount := range quote.Discounts {
+        _, err = tx.Exec(`
+            INSERT INTO order_discounts (order_id, coupon_id, code, description, amount)
+            VALUES (?, ?, ?, ?, ?)`,
+            dbOrderID, discount.CouponID, discount.Code, discount.Description, discount.Amount)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record order discounts"})
+            return
+        }
+    }
+    
+    // Credit any abandoned cart reminders for bringing the customer back
+    _, err = tx.Exec(`
+        UPDATE cart_reminders SET recovered_order_id = ?, recovered_at = NOW()
+        WHERE cart_id = ? AND recovered_order_id IS NULL`,
+        dbOrderID, cartID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record cart recovery"})
+        return
+    }
+    
+    // The stock is now deducted, so the cart's holds are released
+    _, err = tx.Exec("DELETE FROM stock_reservations WHERE cart_id = ?", cartID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release stock reservations"})
+        return
+    }
+    
+    // Clear the cart and its coupon
     _, err = tx.Exec("DELETE FROM cart_items WHERE cart_id = ?", cartID)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear cart"})
         return
     }
     
+    _, err = tx.Exec("DELETE FROM cart_coupons WHERE cart_id = ?", cartID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear cart"})
+        return
+    }
+    
     // Commit transaction
     err = tx.Commit()
     if err != nil {
@@ -307,75 +290,136 @@ func Checkout(c *gin.Context) {
         "email":          user.Email,
         "phone":          shippingInfo.Phone,
         "amount":         totalAmount,
+        "currency":       quote.Currency,
         "description":    orderDescription.String(),
         "address":        fmt.Sprintf("%s, %s %s", shippingInfo.AddressLine1, shippingInfo.City, shippingInfo.PostalCode),
-        "message":        "Order: " + orderID,
+        "message":        "Order: " + orderNumber,
         "feeType":        "include",
         "orderId":        orderID,
         "paymentType":    "QRNONE",
         "agreement":      1,
     }
     
-    paymentJSON, err := json.Marshal(paymentRequest)
+    // Send payment request to Java payment service
+    paymentResponse, err := utils.Payment.CreateQR(c.Request.Context(), paymentRequest)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment request"})
+        if errors.Is(err, utils.ErrPaymentCircuitOpen) {
+            c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to communicate with payment service: " + err.Error()})
         return
     }
     
-    // Send payment request to Java payment service
-    req, err := http.NewRequest("POST", "http://localhost:8088/api/payment/create-qr", bytes.NewBuffer(paymentJSON))
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment request"})
+    // Update the order with payment information
+    if transactionID, ok := paymentResponse["transactionId"].(string); ok {
+        _, err = config.DB.Exec(
+            "UPDATE orders SET transaction_id = ? WHERE id = ?",
+            transactionID, dbOrderID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order with transaction ID"})
+            return
+        }
+    }
+    
+    // Return payment information to the client
+    response := gin.H{
+        "message": "order created successfully",
+        "order_id": orderID,
+        "order_number": orderNumber,
+        "payment": paymentResponse,
+    }
+    
+    // Guests have no account to find the order in, so give them a signed link token
+    if userID == nil {
+        response["order_link_token"] = utils.SignOrderLink(orderID)
+    }
+    
+    c.JSON(http.StatusOK, response)
+}
+
+// CheckoutQuote prices the cart for the given shipping input without creating an order
+func CheckoutQuote(c *gin.Context) {
+    var input models.CheckoutInput
+    
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    req.Header.Set("Content-Type", "application/json")
     
-    client := &http.Client{
-        Timeout: 10 * time.Second,
+    // The email is optional until the guest actually checks out
+    buyer, err := checkoutCustomer(c, input, false)
+    if err != nil {
+        writeCheckoutError(c, err, "invalid checkout input")
+        return
     }
-    resp, err := client.Do(req)
+    
+    currency, err := requestCurrency(config.DB, c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to communicate with payment service: " + err.Error()})
+        writeCheckoutError(c, err, "failed to load currency")
         return
     }
-    defer resp.Body.Close()
     
-    // Check response status
-    if resp.StatusCode != http.StatusOK {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "payment service returned error: " + resp.Status})
+    // Validate the shipping input the same way Checkout does
+    address, err := resolveCheckoutAddress(config.DB, input, buyer.userID)
+    if err != nil {
+        writeCheckoutError(c, err, "database error")
         return
     }
     
-    // Read payment service response
-    body, err := ioutil.ReadAll(resp.Body)
+    // Get cart
+    cartID, err := findCart(config.DB, c)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read payment service response"})
+        if err == sql.ErrNoRows {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "no active cart found"})
+        } else {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+        }
         return
     }
     
-    // Parse payment response
-    var paymentResponse map[string]interface{}
-    err = json.Unmarshal(body, &paymentResponse)
+    quote, err := priceCart(config.DB, cartID, buyer, address, input.ShippingMethodID, currency)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse payment service response"})
+        writeCheckoutError(c, err, "failed to fetch cart items")
         return
     }
     
-    // Update the order with payment information
-    if transactionID, ok := paymentResponse["transactionId"].(string); ok {
-        _, err = config.DB.Exec(
-            "UPDATE orders SET transaction_id = ? WHERE id = ?",
-            transactionID, dbOrderID)
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order with transaction ID"})
-            return
-        }
+    c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+// checkoutCustomer identifies who is checking out. Guests must use an inline
+// shipping address and, when requireEmail is set, give a valid email.
+func checkoutCustomer(c *gin.Context, input models.CheckoutInput, requireEmail bool) (customer, error) {
+    if userID, ok := c.Get("userID"); ok {
+        return customer{userID: userID}, nil
     }
     
-    // Return payment information to the client
-    c.JSON(http.StatusOK, gin.H{
-        "message": "order created successfully",
-        "order_id": orderID,
-        "payment": paymentResponse,
-    })
-}
\ No newline at end of file
+    if input.ShippingAddressID != nil {
+        return customer{}, &checkoutError{"guests must provide shipping_address instead of shipping_address_id"}
+    }
+    
+    email, ok := normalizeEmail(input.Email)
+    if !ok && (requireEmail || input.Email != "") {
+        return customer{}, &checkoutError{"a valid email is required for guest checkout"}
+    }
+    return customer{email: email}, nil
+}
+
+// normalizeEmail trims and lowercases an email address, reporting whether it is valid
+func normalizeEmail(email string) (string, bool) {
+    address, err := mail.ParseAddress(strings.TrimSpace(email))
+    if err != nil || address.Address != strings.TrimSpace(email) {
+        return "", false
+    }
+    return strings.ToLower(address.Address), true
+}
+
+// writeCheckoutError reports customer errors as 400 and anything else as a 500 with fallback
+func writeCheckoutError(c *gin.Context, err error, fallback string) {
+    var checkoutErr *checkoutError
+    if errors.As(err, &checkoutErr) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": checkoutErr.Error()})
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
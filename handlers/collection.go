@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const collectionColumns = `id, name, slug, COALESCE(description, ''), is_active, created_at, updated_at`
+
+// scanCollection reads a row selected with collectionColumns
+func scanCollection(row scanner) (models.Collection, error) {
+	var collection models.Collection
+
+	err := row.Scan(
+		&collection.ID,
+		&collection.Name,
+		&collection.Slug,
+		&collection.Description,
+		&collection.IsActive,
+		&collection.CreatedAt,
+		&collection.UpdatedAt,
+	)
+	return collection, err
+}
+
+// GetCollections lists the active collections
+func GetCollections(c *gin.Context) {
+	listCollections(c, true)
+}
+
+// GetAllCollections lists every collection, including inactive ones (admin only)
+func GetAllCollections(c *gin.Context) {
+	listCollections(c, false)
+}
+
+func listCollections(c *gin.Context, activeOnly bool) {
+	query := `SELECT ` + collectionColumns + ` FROM collections`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY name`
+
+	rows, err := config.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch collections"})
+		return
+	}
+	defer rows.Close()
+
+	collections := []models.Collection{}
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process collections"})
+			return
+		}
+		collections = append(collections, collection)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// GetCollection returns an active collection by slug with its products in curated order
+func GetCollection(c *gin.Context) {
+	collection, err := scanCollection(config.DB.QueryRow(
+		`SELECT `+collectionColumns+` FROM collections WHERE slug = ? AND is_active = TRUE`, c.Param("slug")))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	// Show prices in the requested currency
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	rows, err := config.DB.Query(
+		"SELECT product_id FROM collection_products WHERE collection_id = ? ORDER BY position, product_id", collection.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch collection products"})
+		return
+	}
+
+	var productIDs []int
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process collection products"})
+			return
+		}
+		productIDs = append(productIDs, productID)
+	}
+	rows.Close()
+
+	found, err := loadProductsByID(config.DB, currency, productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch products"})
+		return
+	}
+
+	collection.Products = []models.Product{}
+	for _, productID := range productIDs {
+		if product, ok := found[productID]; ok {
+			collection.Products = append(collection.Products, product)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collection": collection})
+}
+
+// CreateCollection adds a collection (admin only)
+func CreateCollection(c *gin.Context) {
+	var input models.CollectionInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slug := slugify(input.Slug)
+	if slug == "" {
+		slug = slugify(input.Name)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug is required"})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO collections (name, slug, description, is_active)
+		VALUES (?, ?, ?, ?)`,
+		input.Name, slug, input.Description, isActive)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collection slug already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create collection"})
+		return
+	}
+
+	// Get collection ID
+	collectionID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get collection ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "collection created successfully",
+		"collection_id": collectionID,
+		"slug":          slug,
+	})
+}
+
+// UpdateCollection updates a collection (admin only)
+func UpdateCollection(c *gin.Context) {
+	// Get collection ID from URL
+	collectionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection ID"})
+		return
+	}
+
+	var input models.CollectionInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	slug := slugify(input.Slug)
+	if slug == "" {
+		slug = slugify(input.Name)
+	}
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug is required"})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE id = ?)", collectionID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE collections SET name = ?, slug = ?, description = ?, is_active = ?
+		WHERE id = ?`,
+		input.Name, slug, input.Description, isActive, collectionID)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collection slug already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "collection updated successfully"})
+}
+
+// DeleteCollection removes a collection; its products stay in the catalog (admin only)
+func DeleteCollection(c *gin.Context) {
+	// Get collection ID from URL
+	collectionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection ID"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM collections WHERE id = ?", collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete collection"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "collection deleted successfully"})
+}
+
+// UpdateCollectionProducts replaces a collection's products; they are shown
+// in the order given (admin only)
+func UpdateCollectionProducts(c *gin.Context) {
+	// Get collection ID from URL
+	collectionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection ID"})
+		return
+	}
+
+	var input models.CollectionProductsInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM collections WHERE id = ?)", collectionID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM collection_products WHERE collection_id = ?", collectionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update collection products"})
+		return
+	}
+
+	for position, productID := range input.ProductIDs {
+		var productExists bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&productExists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		if !productExists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "product " + strconv.Itoa(productID) + " not found"})
+			return
+		}
+
+		// A product listed twice keeps its first position
+		_, err = tx.Exec(`
+			INSERT IGNORE INTO collection_products (collection_id, product_id, position)
+			VALUES (?, ?, ?)`,
+			collectionID, productID, position)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update collection products"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "collection products updated successfully"})
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const couponColumns = `id, code, description, type, value, min_spend, product_id, variant_id,
+	buy_quantity, get_quantity, usage_limit, usage_limit_per_user, starts_at, ends_at,
+	is_active, created_at, updated_at`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCoupon reads a row selected with couponColumns
+func scanCoupon(row scanner) (models.Coupon, error) {
+	var coupon models.Coupon
+	var productID, variantID, usageLimit, usageLimitPerUser sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+
+	err := row.Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.Description,
+		&coupon.Type,
+		&coupon.Value,
+		&coupon.MinSpend,
+		&productID,
+		&variantID,
+		&coupon.BuyQuantity,
+		&coupon.GetQuantity,
+		&usageLimit,
+		&usageLimitPerUser,
+		&startsAt,
+		&endsAt,
+		&coupon.IsActive,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+	)
+	if err != nil {
+		return coupon, err
+	}
+
+	coupon.ProductID = nullIntPtr(productID)
+	coupon.VariantID = nullIntPtr(variantID)
+	coupon.UsageLimit = nullIntPtr(usageLimit)
+	coupon.UsageLimitPerUser = nullIntPtr(usageLimitPerUser)
+	if startsAt.Valid {
+		coupon.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		coupon.EndsAt = &endsAt.Time
+	}
+
+	return coupon, nil
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+// validateCouponInput checks the fields each coupon type depends on
+func validateCouponInput(input models.CouponInput) string {
+	switch input.Type {
+	case models.CouponPercentage:
+		if input.Value <= 0 || input.Value > models.MoneyFromFloat(100) {
+			return "percentage value must be between 0 and 100"
+		}
+	case models.CouponFixedAmount:
+		if input.Value <= 0 {
+			return "fixed amount value must be greater than 0"
+		}
+	case models.CouponFreeShipping:
+	case models.CouponBuyXGetY:
+		if input.BuyQuantity < 1 || input.GetQuantity < 1 {
+			return "buy_quantity and get_quantity must be at least 1"
+		}
+	default:
+		return "invalid coupon type"
+	}
+
+	if input.MinSpend < 0 {
+		return "min_spend cannot be negative"
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
+
+// checkCouponUsable verifies the coupon is active, in its validity window and under its usage limits
+func checkCouponUsable(q queryer, coupon models.Coupon, buyer customer) error {
+	now := time.Now()
+
+	if !coupon.IsActive {
+		return &checkoutError{"coupon is not active"}
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return &checkoutError{"coupon is not valid yet"}
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return &checkoutError{"coupon has expired"}
+	}
+
+	// Redemptions on cancelled orders don't count towards the limits
+	if coupon.UsageLimit != nil {
+		var used int
+		err := q.QueryRow(`
+			SELECT COUNT(*) FROM order_discounts od
+			JOIN orders o ON od.order_id = o.id
+			WHERE od.coupon_id = ? AND o.status != 'cancelled'`, coupon.ID).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used >= *coupon.UsageLimit {
+			return &checkoutError{"coupon usage limit reached"}
+		}
+	}
+
+	if coupon.UsageLimitPerUser != nil {
+		var used int
+		err := q.QueryRow(`
+			SELECT COUNT(*) FROM order_discounts od
+			JOIN orders o ON od.order_id = o.id
+			WHERE od.coupon_id = ? AND (o.user_id = ? OR o.guest_email = ?) AND o.status != 'cancelled'`,
+			coupon.ID, buyer.userID, buyer.email).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used >= *coupon.UsageLimitPerUser {
+			return &checkoutError{"you have already used this coupon the maximum number of times"}
+		}
+	}
+
+	return nil
+}
+
+// couponApplies reports whether a cart line is inside the coupon's product/variant scope
+func couponApplies(coupon models.Coupon, line models.QuoteLine) bool {
+	if coupon.ProductID != nil && *coupon.ProductID != line.ProductID {
+		return false
+	}
+	if coupon.VariantID != nil && (line.VariantID == nil || *coupon.VariantID != *line.VariantID) {
+		return false
+	}
+	return true
+}
+
+// evaluateCoupon works out the discount a coupon gives on a priced quote
+func evaluateCoupon(coupon models.Coupon, quote *models.CheckoutQuote) (models.AppliedDiscount, error) {
+	discount := models.AppliedDiscount{
+		CouponID:    coupon.ID,
+		Code:        coupon.Code,
+		Description: coupon.Description,
+	}
+
+	// Coupon amounts are set in the base currency
+	if minSpend := quote.FromBase(coupon.MinSpend); quote.Subtotal < minSpend {
+		return discount, &checkoutError{fmt.Sprintf("a minimum spend of %s %s is required for this coupon", minSpend, quote.Currency)}
+	}
+
+	var eligible models.Money
+	var matched bool
+	for _, line := range quote.Lines {
+		if !couponApplies(coupon, line) {
+			continue
+		}
+		matched = true
+		eligible += line.LineTotal
+
+		if coupon.Type == models.CouponBuyXGetY {
+			// Every full group of buy+get units makes get units free
+			freeUnits := line.Quantity / (coupon.BuyQuantity + coupon.GetQuantity) * coupon.GetQuantity
+			discount.Amount += line.UnitPrice.Mul(freeUnits)
+		}
+	}
+
+	if !matched && coupon.Type != models.CouponFreeShipping {
+		return discount, &checkoutError{"coupon does not apply to any item in your cart"}
+	}
+
+	switch coupon.Type {
+	case models.CouponPercentage:
+		discount.Amount = eligible.Percent(coupon.Value.Float64())
+	case models.CouponFixedAmount:
+		discount.Amount = quote.FromBase(coupon.Value)
+		if discount.Amount > eligible {
+			discount.Amount = eligible
+		}
+	case models.CouponFreeShipping:
+		discount.Amount = quote.Shipping
+	case models.CouponBuyXGetY:
+		if discount.Amount == 0 {
+			return discount, &checkoutError{fmt.Sprintf("buy %d to get %d free with this coupon",
+				coupon.BuyQuantity+coupon.GetQuantity, coupon.GetQuantity)}
+		}
+	}
+
+	return discount, nil
+}
+
+// applyCartCoupon adds the discount from the cart's coupon, if any, to the quote
+func applyCartCoupon(q queryer, cartID int, buyer customer, quote *models.CheckoutQuote) error {
+	query := `SELECT ` + couponColumns + ` FROM coupons
+		WHERE id = (SELECT coupon_id FROM cart_coupons WHERE cart_id = ?)`
+	if _, ok := q.(*sql.Tx); ok {
+		// Lock the coupon so concurrent checkouts can't both take the last redemption
+		query += " FOR UPDATE"
+	}
+
+	coupon, err := scanCoupon(q.QueryRow(query, cartID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if err := checkCouponUsable(q, coupon, buyer); err != nil {
+		return err
+	}
+
+	discount, err := evaluateCoupon(coupon, quote)
+	if err != nil {
+		return err
+	}
+
+	quote.Discounts = append(quote.Discounts, discount)
+	quote.Discount += discount.Amount
+	if coupon.Type == models.CouponFreeShipping {
+		quote.ShippingDiscount += discount.Amount
+	}
+	return nil
+}
+
+// ApplyCoupon attaches a coupon code to the user's cart
+func ApplyCoupon(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Guests have no user ID yet; per-user limits are checked again at checkout
+	userID, _ := c.Get("userID")
+	buyer := customer{userID: userID}
+
+	// Find the coupon
+	coupon, err := scanCoupon(config.DB.QueryRow(
+		`SELECT `+couponColumns+` FROM coupons WHERE code = ?`, strings.ToUpper(strings.TrimSpace(input.Code))))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coupon not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	// Get cart
+	cartID, err := findCart(config.DB, c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no active cart found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	// Check the coupon against the current cart before saving it
+	if err := checkCouponUsable(config.DB, coupon, buyer); err != nil {
+		writeCheckoutError(c, err, "database error")
+		return
+	}
+
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	quote, err := priceCartLines(config.DB, cartID, currency)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to fetch cart items")
+		return
+	}
+
+	discount, err := evaluateCoupon(coupon, quote)
+	if err != nil {
+		writeCheckoutError(c, err, "database error")
+		return
+	}
+
+	// A cart holds a single coupon, so applying a new code replaces the old one
+	_, err = config.DB.Exec(`
+		INSERT INTO cart_coupons (cart_id, coupon_id) VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE coupon_id = VALUES(coupon_id)`, cartID, coupon.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to apply coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "coupon applied successfully",
+		"discount": discount,
+	})
+}
+
+// RemoveCoupon detaches the coupon from the cart of the user or guest
+func RemoveCoupon(c *gin.Context) {
+	cartID, err := findCart(config.DB, c)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"message": "coupon removed successfully"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	_, err = config.DB.Exec("DELETE FROM cart_coupons WHERE cart_id = ?", cartID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove coupon"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "coupon removed successfully"})
+}
+
+// GetAllCoupons retrieves all coupons (admin only)
+func GetAllCoupons(c *gin.Context) {
+	coupons := []models.Coupon{}
+
+	rows, err := config.DB.Query(`SELECT ` + couponColumns + ` FROM coupons ORDER BY created_at DESC`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch coupons"})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process coupons"})
+			return
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupons": coupons})
+}
+
+// CreateCoupon adds a new coupon (admin only)
+func CreateCoupon(c *gin.Context) {
+	var input models.CouponInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.Code = strings.ToUpper(strings.TrimSpace(input.Code))
+	if msg := validateCouponInput(input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO coupons (
+			code, description, type, value, min_spend, product_id, variant_id,
+			buy_quantity, get_quantity, usage_limit, usage_limit_per_user, starts_at, ends_at, is_active
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		input.Code, input.Description, input.Type, input.Value, input.MinSpend, input.ProductID, input.VariantID,
+		input.BuyQuantity, input.GetQuantity, input.UsageLimit, input.UsageLimitPerUser,
+		input.StartsAt, input.EndsAt, isActive,
+	)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "coupon code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create coupon"})
+		return
+	}
+
+	// Get coupon ID
+	couponID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get coupon ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "coupon created successfully",
+		"coupon_id": couponID,
+	})
+}
+
+// UpdateCoupon updates an existing coupon (admin only)
+func UpdateCoupon(c *gin.Context) {
+	// Get coupon ID from URL
+	couponID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid coupon ID"})
+		return
+	}
+
+	var input models.CouponInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.Code = strings.ToUpper(strings.TrimSpace(input.Code))
+	if msg := validateCouponInput(input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	result, err := config.DB.Exec(`
+		UPDATE coupons SET
+			code = ?, description = ?, type = ?, value = ?, min_spend = ?, product_id = ?, variant_id = ?,
+			buy_quantity = ?, get_quantity = ?, usage_limit = ?, usage_limit_per_user = ?,
+			starts_at = ?, ends_at = ?, is_active = ?
+		WHERE id = ?`,
+		input.Code, input.Description, input.Type, input.Value, input.MinSpend, input.ProductID, input.VariantID,
+		input.BuyQuantity, input.GetQuantity, input.UsageLimit, input.UsageLimitPerUser,
+		input.StartsAt, input.EndsAt, isActive, couponID,
+	)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "coupon code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update coupon"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		// MySQL reports 0 rows for an unchanged row, so check it really is missing
+		var exists bool
+		err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM coupons WHERE id = ?)", couponID).Scan(&exists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coupon not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "coupon updated successfully"})
+}
+
+// DeleteCoupon removes a coupon (admin only). Orders keep the code and amount they were given.
+func DeleteCoupon(c *gin.Context) {
+	// Get coupon ID from URL
+	couponID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid coupon ID"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM coupons WHERE id = ?", couponID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete coupon"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "coupon not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "coupon deleted successfully"})
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const currencyColumns = `code, name, symbol, exchange_rate, is_base, is_active, updated_at`
+
+// productPriceJoin attaches a product's price override in one currency.
+// Pass the currency code as the query argument and select pp.price.
+const productPriceJoin = `LEFT JOIN product_prices pp ON pp.product_id = p.id AND pp.currency = ?`
+
+// scanCurrency reads a row selected with currencyColumns
+func scanCurrency(row scanner) (models.Currency, error) {
+	var currency models.Currency
+
+	err := row.Scan(
+		&currency.Code,
+		&currency.Name,
+		&currency.Symbol,
+		&currency.ExchangeRate,
+		&currency.IsBase,
+		&currency.IsActive,
+		&currency.UpdatedAt,
+	)
+	return currency, err
+}
+
+// requestCurrency returns the currency a request asked for with the currency
+// query parameter or the X-Currency header, defaulting to the base currency
+func requestCurrency(q queryer, c *gin.Context) (models.Currency, error) {
+	code := c.Query("currency")
+	if code == "" {
+		code = c.GetHeader("X-Currency")
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if code == "" {
+		return scanCurrency(q.QueryRow(`SELECT ` + currencyColumns + ` FROM currencies WHERE is_base = TRUE`))
+	}
+
+	currency, err := scanCurrency(q.QueryRow(
+		`SELECT `+currencyColumns+` FROM currencies WHERE code = ? AND is_active = TRUE`, code))
+	if err == sql.ErrNoRows {
+		return currency, &checkoutError{"unsupported currency " + code}
+	}
+	return currency, err
+}
+
+// localPrice is a product's price in a currency: the override when one is
+// set, otherwise the base price converted at the currency's exchange rate
+func localPrice(base models.Money, override *models.Money, currency models.Currency) models.Money {
+	if override != nil {
+		return *override
+	}
+	return currency.FromBase(base)
+}
+
+// GetCurrencies lists the currencies customers can choose from
+func GetCurrencies(c *gin.Context) {
+	listCurrencies(c, true)
+}
+
+// GetAllCurrencies lists every currency (admin only)
+func GetAllCurrencies(c *gin.Context) {
+	listCurrencies(c, false)
+}
+
+func listCurrencies(c *gin.Context, activeOnly bool) {
+	query := `SELECT ` + currencyColumns + ` FROM currencies`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY is_base DESC, code`
+
+	rows, err := config.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch currencies"})
+		return
+	}
+	defer rows.Close()
+
+	currencies := []models.Currency{}
+	for rows.Next() {
+		currency, err := scanCurrency(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process currencies"})
+			return
+		}
+		currencies = append(currencies, currency)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"currencies": currencies})
+}
+
+// SaveCurrency creates a currency or updates its name and exchange rate (admin only)
+func SaveCurrency(c *gin.Context) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	if len(code) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency code must be three letters"})
+		return
+	}
+
+	var input models.CurrencyInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.ExchangeRate <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exchange_rate must be greater than 0"})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	// Prices are stored in the base currency, so its rate is fixed at 1
+	var isBase bool
+	err := config.DB.QueryRow("SELECT is_base FROM currencies WHERE code = ?", code).Scan(&isBase)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+	if isBase && (input.ExchangeRate != 1 || !isActive) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the base currency must stay active with an exchange rate of 1"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		INSERT INTO currencies (code, name, symbol, exchange_rate, is_active) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE name = VALUES(name), symbol = VALUES(symbol),
+			exchange_rate = VALUES(exchange_rate), is_active = VALUES(is_active)`,
+		code, input.Name, input.Symbol, input.ExchangeRate, isActive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "currency saved successfully"})
+}
+
+// DeleteCurrency removes a currency and its price overrides (admin only)
+func DeleteCurrency(c *gin.Context) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+
+	result, err := config.DB.Exec("DELETE FROM currencies WHERE code = ? AND is_base = FALSE", code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete currency"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "currency not found or is the base currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "currency deleted successfully"})
+}
+
+// loadProductPrices returns a product's per-currency price overrides
+func loadProductPrices(q queryer, productID int) ([]models.ProductPrice, error) {
+	rows, err := q.Query("SELECT currency, price FROM product_prices WHERE product_id = ? ORDER BY currency", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.ProductPrice
+	for rows.Next() {
+		var price models.ProductPrice
+		if err := rows.Scan(&price.Currency, &price.Price); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// UpdateProductPrices replaces a product's per-currency price overrides (admin only)
+func UpdateProductPrices(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var input []models.ProductPrice
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Check if product exists
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM product_prices WHERE product_id = ?", productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product prices"})
+		return
+	}
+
+	for _, price := range input {
+		code := strings.ToUpper(strings.TrimSpace(price.Currency))
+		if price.Price <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "price for " + code + " must be greater than 0"})
+			return
+		}
+
+		// The base price lives on the product itself
+		var isBase bool
+		err := tx.QueryRow("SELECT is_base FROM currencies WHERE code = ?", code).Scan(&isBase)
+		if err == sql.ErrNoRows || isBase {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot set a price override for " + code})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO product_prices (product_id, currency, price) VALUES (?, ?, ?)",
+			productID, code, price.Price)
+		if err != nil {
+			if config.IsDuplicateKey(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate price for " + code})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product prices"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product prices updated successfully"})
+}
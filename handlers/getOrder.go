@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 	"goapi/config" //change this to your module
+	"goapi/models" //change this to your module
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,10 +28,10 @@ func GetAllOrders(c *gin.Context) {
     
     // Build query
     query := `
-        SELECT o.id, o.order_id, o.user_id, u.username, o.total_amount, o.status, 
+        SELECT o.id, o.order_id, o.order_number, o.user_id, COALESCE(u.username, ''), o.guest_email, o.total_amount, o.currency, o.status, 
                o.transaction_id, o.created_at, COUNT(oi.id) as item_count
         FROM orders o
-        JOIN users u ON o.user_id = u.id
+        LEFT JOIN users u ON o.user_id = u.id
         JOIN order_items oi ON o.id = oi.order_id
     `
     countQuery := `SELECT COUNT(*) FROM orders o`
@@ -68,9 +69,12 @@ func GetAllOrders(c *gin.Context) {
         var order struct {
             ID            int       `json:"id"`
             OrderID       string    `json:"order_id"`
-            UserID        int       `json:"user_id"`
+            OrderNumber   string    `json:"order_number"`
+            UserID        sql.NullInt64 `json:"user_id"`
             Username      string    `json:"username"`
-            TotalAmount   float64   `json:"total_amount"`
+            GuestEmail    sql.NullString `json:"guest_email"`
+            TotalAmount   models.Money `json:"total_amount"`
+            Currency      string    `json:"currency"`
             Status        string    `json:"status"`
             TransactionID sql.NullString `json:"transaction_id"`
             CreatedAt     time.Time `json:"created_at"`
@@ -80,9 +84,12 @@ func GetAllOrders(c *gin.Context) {
         err := rows.Scan(
             &order.ID,
             &order.OrderID,
+            &order.OrderNumber,
             &order.UserID,
             &order.Username,
+            &order.GuestEmail,
             &order.TotalAmount,
+            &order.Currency,
             &order.Status,
             &order.TransactionID,
             &order.CreatedAt,
@@ -96,9 +103,12 @@ func GetAllOrders(c *gin.Context) {
         orderMap := map[string]interface{}{
             "id":           order.ID,
             "order_id":     order.OrderID,
-            "user_id":      order.UserID,
+            "order_number": order.OrderNumber,
+            "user_id":      nullIntPtr(order.UserID),
             "username":     order.Username,
+            "guest_email":  order.GuestEmail.String,
             "total_amount": order.TotalAmount,
+            "currency":     order.Currency,
             "status":       order.Status,
             "created_at":   order.CreatedAt,
             "item_count":   order.ItemCount,
@@ -181,7 +191,7 @@ func UpdateOrderStatus(c *gin.Context) {
     // Handle special cases for status changes
     if currentStatus == "cancelled" && input.Status != "cancelled" {
         // If reactivating a cancelled order, restore the stock reservation
-        rows, err := tx.Query("SELECT product_id, quantity FROM order_items WHERE order_id = ?", dbOrderID)
+        rows, err := tx.Query("SELECT product_id, variant_id, quantity FROM order_items WHERE order_id = ?", dbOrderID)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order items"})
             return
@@ -190,16 +200,21 @@ func UpdateOrderStatus(c *gin.Context) {
         
         for rows.Next() {
             var productID, quantity int
+            var variantID sql.NullInt64
             
-            err := rows.Scan(&productID, &quantity)
+            err := rows.Scan(&productID, &variantID, &quantity)
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order items"})
                 return
             }
             
-            // Check if we have enough stock
+            // Check if we have enough stock of the product, or the variant when there is one
             var currentStock int
-            err = tx.QueryRow("SELECT stock FROM products WHERE id = ?", productID).Scan(&currentStock)
+            if variantID.Valid {
+                err = tx.QueryRow("SELECT stock FROM product_variants WHERE id = ? FOR UPDATE", variantID.Int64).Scan(&currentStock)
+            } else {
+                err = tx.QueryRow("SELECT stock FROM products WHERE id = ? FOR UPDATE", productID).Scan(&currentStock)
+            }
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get product stock"})
                 return
@@ -213,7 +228,13 @@ func UpdateOrderStatus(c *gin.Context) {
             }
             
             // Deduct stock
-            _, err = tx.Exec("UPDATE products SET stock = stock - ? WHERE id = ?", quantity, productID)
+            err = adjustStock(tx, productID, nullIntPtr(variantID), -quantity)
+            if err == errOutOfStock {
+                c.JSON(http.StatusBadRequest, gin.H{
+                    "error": fmt.Sprintf("Not enough stock to fulfill this order (Product ID: %d)", productID),
+                })
+                return
+            }
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product stock"})
                 return
@@ -221,7 +242,7 @@ func UpdateOrderStatus(c *gin.Context) {
         }
     } else if currentStatus != "cancelled" && input.Status == "cancelled" {
         // If cancelling an order, release the stock reservation
-        rows, err := tx.Query("SELECT product_id, quantity FROM order_items WHERE order_id = ?", dbOrderID)
+        rows, err := tx.Query("SELECT product_id, variant_id, quantity FROM order_items WHERE order_id = ?", dbOrderID)
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order items"})
             return
@@ -230,15 +251,16 @@ func UpdateOrderStatus(c *gin.Context) {
         
         for rows.Next() {
             var productID, quantity int
+            var variantID sql.NullInt64
             
-            err := rows.Scan(&productID, &quantity)
+            err := rows.Scan(&productID, &variantID, &quantity)
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order items"})
                 return
             }
             
             // Restore stock
-            _, err = tx.Exec("UPDATE products SET stock = stock + ? WHERE id = ?", quantity, productID)
+            err = adjustStock(tx, productID, nullIntPtr(variantID), quantity)
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product stock"})
                 return
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+
+	"goapi/config"
+	"goapi/models"
+	"goapi/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cartTokenHeader carries the opaque token that identifies a guest's cart
+const cartTokenHeader = "X-Cart-Token"
+
+// findCart returns the cart a request works on: the signed-in user's cart,
+// or the guest cart named by the X-Cart-Token header. It returns
+// sql.ErrNoRows when there is none yet.
+func findCart(q queryer, c *gin.Context) (int, error) {
+	var cartID int
+
+	if userID, ok := c.Get("userID"); ok {
+		err := q.QueryRow("SELECT id FROM carts WHERE user_id = ?", userID).Scan(&cartID)
+		return cartID, err
+	}
+
+	// A cart created earlier in this request has its token in the response
+	token := c.Writer.Header().Get(cartTokenHeader)
+	if token == "" {
+		token = c.GetHeader(cartTokenHeader)
+	}
+	if token == "" {
+		return 0, sql.ErrNoRows
+	}
+	err := q.QueryRow("SELECT id FROM carts WHERE token = ? AND user_id IS NULL", token).Scan(&cartID)
+	return cartID, err
+}
+
+// findOrCreateCart returns the request's cart, creating one if needed. New
+// guest carts get a fresh token, returned in the X-Cart-Token response header.
+func findOrCreateCart(q queryer, c *gin.Context) (int, error) {
+	cartID, err := findCart(q, c)
+	if err != sql.ErrNoRows {
+		return cartID, err
+	}
+
+	var result sql.Result
+	if userID, ok := c.Get("userID"); ok {
+		result, err = q.Exec("INSERT INTO carts (user_id) VALUES (?)", userID)
+	} else {
+		token := utils.NewCartToken()
+		result, err = q.Exec("INSERT INTO carts (token) VALUES (?)", token)
+		c.Header(cartTokenHeader, token)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cartID64, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(cartID64), nil
+}
+
+// cartToken returns the guest cart token the client should keep sending, or
+// an empty string for signed-in users
+func cartToken(c *gin.Context) string {
+	if _, ok := c.Get("userID"); ok {
+		return ""
+	}
+	if token := c.Writer.Header().Get(cartTokenHeader); token != "" {
+		return token
+	}
+	return c.GetHeader(cartTokenHeader)
+}
+
+// mergeGuestCart moves a guest cart into the user's cart. Lines for the same
+// product and variant are combined, quantities are capped at the stock
+// available, and lines for products no longer on sale are dropped; every
+// capped or dropped line is reported back. The guest's coupon is kept only if
+// the user's cart has none.
+func mergeGuestCart(userID int, token string) ([]models.CartMergeAdjustment, error) {
+	adjustments := []models.CartMergeAdjustment{}
+	if token == "" {
+		return adjustments, nil
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var guestCartID int
+	err = tx.QueryRow("SELECT id FROM carts WHERE token = ? AND user_id IS NULL FOR UPDATE", token).Scan(&guestCartID)
+	if err == sql.ErrNoRows {
+		return adjustments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var userCartID int
+	err = tx.QueryRow("SELECT id FROM carts WHERE user_id = ? FOR UPDATE", userID).Scan(&userCartID)
+	if err == sql.ErrNoRows {
+		// No cart yet, so the guest cart simply becomes the user's
+		_, err = tx.Exec("UPDATE carts SET user_id = ?, token = NULL WHERE id = ?", userID, guestCartID)
+		if err != nil {
+			return nil, err
+		}
+		return adjustments, tx.Commit()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	type guestLine struct {
+		productID int
+		variantID sql.NullInt64
+		quantity  int
+	}
+
+	rows, err := tx.Query("SELECT product_id, variant_id, quantity FROM cart_items WHERE cart_id = ?", guestCartID)
+	if err != nil {
+		return nil, err
+	}
+	var lines []guestLine
+	for rows.Next() {
+		var line guestLine
+		if err := rows.Scan(&line.productID, &line.variantID, &line.quantity); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Release the guest cart's holds so they don't count against its own lines
+	if _, err := tx.Exec("DELETE FROM cart_items WHERE cart_id = ?", guestCartID); err != nil {
+		return nil, err
+	}
+
+	for _, line := range lines {
+		available, err := mergeStock(tx, line.productID, int(line.variantID.Int64), userCartID)
+		if err != nil {
+			return nil, err
+		}
+
+		var existingItemID, existingQuantity int
+		err = tx.QueryRow("SELECT id, quantity FROM cart_items WHERE cart_id = ? AND product_id = ? AND variant_id <=> ?",
+			userCartID, line.productID, line.variantID).Scan(&existingItemID, &existingQuantity)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		requested := existingQuantity + line.quantity
+		quantity := min(requested, max(available, 0))
+		if quantity < requested {
+			adjustment := models.CartMergeAdjustment{
+				ProductID:         line.productID,
+				RequestedQuantity: requested,
+				Quantity:          quantity,
+			}
+			adjustment.VariantID = nullIntPtr(line.variantID)
+			adjustments = append(adjustments, adjustment)
+		}
+
+		itemID := existingItemID
+		switch {
+		case existingItemID != 0 && quantity > 0:
+			_, err = tx.Exec("UPDATE cart_items SET quantity = ? WHERE id = ?", quantity, existingItemID)
+		case existingItemID != 0:
+			_, err = tx.Exec("DELETE FROM cart_items WHERE id = ?", existingItemID)
+		case quantity > 0:
+			var result sql.Result
+			result, err = tx.Exec("INSERT INTO cart_items (cart_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)",
+				userCartID, line.productID, line.variantID, quantity)
+			if err == nil {
+				var itemID64 int64
+				itemID64, err = result.LastInsertId()
+				itemID = int(itemID64)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if quantity > 0 {
+			if err := reserveCartItem(tx, itemID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Keep the user's own coupon if they already had one
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO cart_coupons (cart_id, coupon_id)
+		SELECT ?, coupon_id FROM cart_coupons WHERE cart_id = ?`, userCartID, guestCartID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM cart_coupons WHERE cart_id = ?",
+		"DELETE FROM carts WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, guestCartID); err != nil {
+			return nil, err
+		}
+	}
+
+	return adjustments, tx.Commit()
+}
+
+// mergeStock is how much of a product (and variant, if non-zero) a cart can
+// take when a guest cart is merged into it: none when the product isn't
+// published or the variant is gone, otherwise the stock left once other
+// carts' holds are taken out, as when adding to a cart
+func mergeStock(tx *sql.Tx, productID, variantID, cartID int) (int, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM products WHERE id = ?", productID).Scan(&status)
+	if err == sql.ErrNoRows || (err == nil && status != models.ProductPublished) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := lockProductStock(tx, productID); err != nil {
+		return 0, err
+	}
+
+	available, err := cartStock(tx, productID, variantID, cartID)
+	if _, ok := err.(*cartItemError); ok {
+		return 0, nil
+	}
+	return available, err
+}
+
+// mergeGuestCartOnLogin merges the request's guest cart into the user's cart.
+// A failed merge is logged rather than failing the login; the guest cart is
+// left intact so a later login can merge it.
+func mergeGuestCartOnLogin(c *gin.Context, userID int) []models.CartMergeAdjustment {
+	adjustments, err := mergeGuestCart(userID, c.GetHeader(cartTokenHeader))
+	if err != nil {
+		log.Printf("failed to merge guest cart for user %d: %v", userID, err)
+		return []models.CartMergeAdjustment{}
+	}
+	return adjustments
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"goapi/config" //change this to your module
+	"goapi/utils" //change this to your module
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,3 +17,8 @@ func CheckConnection(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Hello This is Your Go API Project"})
 }
+
+// GetPaymentMetrics returns the payment client counters (admin only)
+func GetPaymentMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"payment": utils.Payment.Metrics()})
+}
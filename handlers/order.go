@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"goapi/config" //change this to your module
+	"goapi/models" //change this to your module
 	
 	"github.com/gin-gonic/gin"
 
@@ -22,7 +23,7 @@ func GetOrders(c *gin.Context) {
     
     // Query orders from database
     rows, err := config.DB.Query(`
-        SELECT id, order_id, total_amount, status, transaction_id, created_at 
+        SELECT id, order_id, order_number, total_amount, currency, status, transaction_id, created_at 
         FROM orders 
         WHERE user_id = ? 
         ORDER BY created_at DESC`, userID)
@@ -38,7 +39,9 @@ func GetOrders(c *gin.Context) {
         var order struct {
             ID            int       `json:"id"`
             OrderID       string    `json:"order_id"`
-            TotalAmount   float64   `json:"total_amount"`
+            OrderNumber   string    `json:"order_number"`
+            TotalAmount   models.Money `json:"total_amount"`
+            Currency      string    `json:"currency"`
             Status        string    `json:"status"`
             TransactionID sql.NullString `json:"transaction_id"`
             CreatedAt     time.Time `json:"created_at"`
@@ -47,7 +50,9 @@ func GetOrders(c *gin.Context) {
         err := rows.Scan(
             &order.ID,
             &order.OrderID,
+            &order.OrderNumber,
             &order.TotalAmount,
+            &order.Currency,
             &order.Status,
             &order.TransactionID,
             &order.CreatedAt,
@@ -60,7 +65,9 @@ func GetOrders(c *gin.Context) {
         orderMap := map[string]interface{}{
             "id":          order.ID,
             "order_id":    order.OrderID,
+            "order_number": order.OrderNumber,
             "total_amount": order.TotalAmount,
+            "currency":    order.Currency,
             "status":      order.Status,
             "created_at":  order.CreatedAt,
         }
@@ -2,20 +2,20 @@ package handlers
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 
 	"goapi/config" //change this to your module
+	"goapi/models" //change this to your module
+	"goapi/utils" //change this to your module
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetOrderDetails retrieves detailed information about a specific order
 func GetOrderDetails(c *gin.Context) {
-    // Get order ID from URL
+    // Get order ID (or receipt order number) from URL
     orderID := c.Param("id")
     
     // Get user ID from context
@@ -25,24 +25,144 @@ func GetOrderDetails(c *gin.Context) {
         return
     }
     
-    // First verify the order belongs to the user
+    // Only show the order if it belongs to the user
+    writeOrderDetails(c, "(order_id = ? OR order_number = ?) AND user_id = ?", orderID, orderID, userID)
+}
+
+// LookupGuestOrder shows an order to a guest who gives its order number and checkout email
+func LookupGuestOrder(c *gin.Context) {
+    var input struct {
+        OrderNumber string `json:"order_number" binding:"required"`
+        Email       string `json:"email" binding:"required"`
+    }
+    
+    // Parse request body
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    email, ok := normalizeEmail(input.Email)
+    if !ok {
+        c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+        return
+    }
+    
+    writeOrderDetails(c, "order_number = ? AND guest_email = ?", strings.TrimSpace(input.OrderNumber), email)
+}
+
+// GetOrderByLink shows the order a signed guest order link points to
+func GetOrderByLink(c *gin.Context) {
+    orderID, err := utils.VerifyOrderLink(c.Param("token"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+        return
+    }
+    
+    writeOrderDetails(c, "order_id = ?", orderID)
+}
+
+// ClaimGuestOrder moves one guest order into the signed-in user's account.
+// The user proves the order is theirs with its signed order link token, or
+// with its order number and checkout email, the same as looking it up.
+func ClaimGuestOrder(c *gin.Context) {
+    var input struct {
+        Token       string `json:"token"`
+        OrderNumber string `json:"order_number"`
+        Email       string `json:"email"`
+    }
+    
+    // Parse request body
+    if err := c.ShouldBindJSON(&input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    // Get user ID from context
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+        return
+    }
+    
+    var result sql.Result
+    var err error
+    switch {
+    case input.Token != "":
+        orderID, linkErr := utils.VerifyOrderLink(input.Token)
+        if linkErr != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+            return
+        }
+        result, err = config.DB.Exec("UPDATE orders SET user_id = ? WHERE order_id = ? AND user_id IS NULL",
+            userID, orderID)
+    case input.OrderNumber != "" && input.Email != "":
+        email, ok := normalizeEmail(input.Email)
+        if !ok {
+            c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+            return
+        }
+        result, err = config.DB.Exec("UPDATE orders SET user_id = ? WHERE order_number = ? AND guest_email = ? AND user_id IS NULL",
+            userID, strings.TrimSpace(input.OrderNumber), email)
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "token, or order_number and email, is required"})
+        return
+    }
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to claim order"})
+        return
+    }
+    
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+        return
+    }
+    
+    if rowsAffected == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+        return
+    }
+    
+    c.JSON(http.StatusOK, gin.H{"message": "order added to your account"})
+}
+
+// writeOrderDetails responds with the single order matching the condition
+func writeOrderDetails(c *gin.Context, condition string, args ...interface{}) {
     var dbOrderID int
     var orderDetails struct {
         OrderID       string    `json:"order_id"`
-        TotalAmount   float64   `json:"total_amount"`
+        OrderNumber   string    `json:"order_number"`
+        TotalAmount   models.Money `json:"total_amount"`
+        Currency      string    `json:"currency"`
+        ExchangeRate  float64   `json:"exchange_rate"`
+        DiscountAmount models.Money `json:"discount_amount"`
+        TaxAmount     models.Money `json:"tax_amount"`
+        PricesIncludeTax bool   `json:"prices_include_tax"`
+        ShippingMethod sql.NullString `json:"shipping_method"`
+        ShippingAmount models.Money `json:"shipping_amount"`
         Status        string    `json:"status"`
         TransactionID sql.NullString `json:"transaction_id"`
         CreatedAt     time.Time `json:"created_at"`
     }
     
     err := config.DB.QueryRow(`
-        SELECT id, order_id, total_amount, status, transaction_id, created_at 
+        SELECT id, order_id, order_number, total_amount, currency, exchange_rate, discount_amount, tax_amount, prices_include_tax, 
+               shipping_method, shipping_amount, status, transaction_id, created_at 
         FROM orders 
-        WHERE order_id = ? AND user_id = ?`, 
-        orderID, userID).Scan(
+        WHERE `+condition, 
+        args...).Scan(
             &dbOrderID,
             &orderDetails.OrderID,
+            &orderDetails.OrderNumber,
             &orderDetails.TotalAmount,
+            &orderDetails.Currency,
+            &orderDetails.ExchangeRate,
+            &orderDetails.DiscountAmount,
+            &orderDetails.TaxAmount,
+            &orderDetails.PricesIncludeTax,
+            &orderDetails.ShippingMethod,
+            &orderDetails.ShippingAmount,
             &orderDetails.Status,
             &orderDetails.TransactionID,
             &orderDetails.CreatedAt,
@@ -59,7 +179,7 @@ func GetOrderDetails(c *gin.Context) {
     
     // Get order items
     rows, err := config.DB.Query(`
-        SELECT oi.product_id, oi.quantity, oi.price, p.name, p.description 
+        SELECT oi.product_id, oi.variant_id, COALESCE(oi.sku, ''), COALESCE(oi.variant_title, ''), oi.quantity, oi.price, oi.tax_class, oi.tax_rate, oi.tax_amount, p.name, p.description 
         FROM order_items oi
         JOIN products p ON oi.product_id = p.id
         WHERE oi.order_id = ?`, 
@@ -75,16 +195,28 @@ func GetOrderDetails(c *gin.Context) {
     for rows.Next() {
         var item struct {
             ProductID   int     `json:"product_id"`
+            VariantID   sql.NullInt64 `json:"variant_id"`
+            SKU         string  `json:"sku"`
+            VariantTitle string `json:"variant_title"`
             Quantity    int     `json:"quantity"`
-            Price       float64 `json:"price"`
+            Price       models.Money `json:"price"`
+            TaxClass    sql.NullString `json:"tax_class"`
+            TaxRate     float64 `json:"tax_rate"`
+            TaxAmount   models.Money `json:"tax_amount"`
             Name        string  `json:"name"`
             Description string  `json:"description"`
         }
         
         err := rows.Scan(
             &item.ProductID,
+            &item.VariantID,
+            &item.SKU,
+            &item.VariantTitle,
             &item.Quantity,
             &item.Price,
+            &item.TaxClass,
+            &item.TaxRate,
+            &item.TaxAmount,
             &item.Name,
             &item.Description,
         )
@@ -95,42 +227,83 @@ func GetOrderDetails(c *gin.Context) {
         
         items = append(items, map[string]interface{}{
             "product_id":  item.ProductID,
+            "variant_id":  nullIntPtr(item.VariantID),
+            "sku":         item.SKU,
+            "variant_title": item.VariantTitle,
             "quantity":    item.Quantity,
             "price":       item.Price,
-            "total_price": item.Price * float64(item.Quantity),
+            "total_price": item.Price.Mul(item.Quantity),
+            "tax_class":   item.TaxClass.String,
+            "tax_rate":    item.TaxRate,
+            "tax_amount":  item.TaxAmount,
             "name":        item.Name,
             "description": item.Description,
         })
     }
     
+    // Get discounts applied at checkout
+    discountRows, err := config.DB.Query(`
+        SELECT coupon_id, code, description, amount 
+        FROM order_discounts 
+        WHERE order_id = ?`, 
+        dbOrderID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order discounts"})
+        return
+    }
+    defer discountRows.Close()
+    
+    discounts := []models.AppliedDiscount{}
+    
+    for discountRows.Next() {
+        var discount models.AppliedDiscount
+        var couponID sql.NullInt64
+        
+        err := discountRows.Scan(&couponID, &discount.Code, &discount.Description, &discount.Amount)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order discounts"})
+            return
+        }
+        
+        discount.CouponID = int(couponID.Int64)
+        discounts = append(discounts, discount)
+    }
+    
+    // Get the per-rate tax breakdown
+    taxRows, err := config.DB.Query(`
+        SELECT tax_class, rate, taxable_amount, tax_amount 
+        FROM order_taxes 
+        WHERE order_id = ?`, 
+        dbOrderID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order taxes"})
+        return
+    }
+    defer taxRows.Close()
+    
+    taxBreakdown := []models.TaxBreakdown{}
+    
+    for taxRows.Next() {
+        var tax models.TaxBreakdown
+        
+        err := taxRows.Scan(&tax.TaxClass, &tax.Rate, &tax.TaxableAmount, &tax.TaxAmount)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order taxes"})
+            return
+        }
+        
+        taxBreakdown = append(taxBreakdown, tax)
+    }
+    
     // Check payment status if transaction ID exists
     var paymentStatus string
     if orderDetails.TransactionID.Valid {
-        // You can add code here to call your payment service API to get payment status
         paymentStatus = "unknown" // Default value
         
-        // Create a request to your Java payment service
-        req, err := http.NewRequest("GET", 
-            fmt.Sprintf("https://cunning-smoothly-aphid.ngrok-free.app/api/payment/status/%s", orderDetails.TransactionID.String), 
-            nil)
-        
-        if err == nil {
-            client := &http.Client{}
-            resp, err := client.Do(req)
-            
-            if err == nil && resp.StatusCode == http.StatusOK {
-                defer resp.Body.Close()
-                
-                body, err := ioutil.ReadAll(resp.Body)
-                if err == nil {
-                    var paymentResponse map[string]interface{}
-                    err = json.Unmarshal(body, &paymentResponse)
-                    
-                    if err == nil && paymentResponse["status"] != nil {
-                        paymentStatus = paymentResponse["status"].(string)
-                    }
-                }
-            }
+        // Ask the Java payment service for the current status
+        status, err := utils.Payment.Status(c.Request.Context(), orderDetails.TransactionID.String)
+        if err == nil && status != "" {
+            paymentStatus = status
         }
     } else {
         paymentStatus = "not_initiated"
@@ -139,7 +312,17 @@ func GetOrderDetails(c *gin.Context) {
     // Prepare response
     response := map[string]interface{}{
         "order_id":      orderDetails.OrderID,
+        "order_number":  orderDetails.OrderNumber,
         "total_amount":  orderDetails.TotalAmount,
+        "currency":      orderDetails.Currency,
+        "exchange_rate": orderDetails.ExchangeRate,
+        "discount_amount": orderDetails.DiscountAmount,
+        "discounts":     discounts,
+        "tax_amount":    orderDetails.TaxAmount,
+        "tax_breakdown": taxBreakdown,
+        "prices_include_tax": orderDetails.PricesIncludeTax,
+        "shipping_method": orderDetails.ShippingMethod.String,
+        "shipping_amount": orderDetails.ShippingAmount,
         "status":        orderDetails.Status,
         "created_at":    orderDetails.CreatedAt,
         "items":         items,
@@ -1,8 +1,10 @@
 package handlers
 import (
+	"database/sql"
 	"net/http"
 	"strings"
 	"goapi/config" //change this to your module
+	"goapi/models" //change this to your module
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,7 +14,8 @@ func PaymentWebhook(c *gin.Context) {
         OrderID        string `json:"orderId"`
         TransactionID  string `json:"transactionId"`
         Status         string `json:"status"`
-        Amount         float64 `json:"amount,omitempty"`
+        Amount         models.Money `json:"amount,omitempty"`
+        Currency       string `json:"currency,omitempty"`
     }
     
     // Parse request body
@@ -27,6 +30,26 @@ func PaymentWebhook(c *gin.Context) {
         return
     }
     
+    // A payment in another currency than the order was charged in is not ours to accept
+    if payload.Currency != "" {
+        var orderCurrency string
+        err := config.DB.QueryRow(
+            "SELECT currency FROM orders WHERE order_id = ? AND transaction_id = ?",
+            payload.OrderID, payload.TransactionID).Scan(&orderCurrency)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+            } else {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+            }
+            return
+        }
+        if !strings.EqualFold(orderCurrency, payload.Currency) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "payment currency does not match order currency"})
+            return
+        }
+    }
+    
     // Map payment status to order status
     var orderStatus string
     switch strings.ToUpper(payload.Status) {
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+
+	"goapi/models"
+	"goapi/utils"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// checkoutError is a problem with the customer's cart or input rather than the server
+type checkoutError struct {
+	message string
+}
+
+func (e *checkoutError) Error() string {
+	return e.message
+}
+
+// customer is who a cart is priced for: a signed-in user, or a guest known
+// only by the email given at checkout
+type customer struct {
+	userID interface{} // nil for guests
+	email  string      // guest email; empty for signed-in users
+}
+
+// loadShippingAddress fetches a saved address that belongs to the user
+func loadShippingAddress(q queryer, addressID int, userID interface{}) (models.ShippingAddress, error) {
+	var address models.ShippingAddress
+	var addressLine2 sql.NullString
+
+	err := q.QueryRow(`
+		SELECT id, recipient_name, phone, address_line1, address_line2, city, state, postal_code, country
+		FROM shipping_addresses
+		WHERE id = ? AND user_id = ?`,
+		addressID, userID).Scan(
+		&address.ID,
+		&address.RecipientName,
+		&address.Phone,
+		&address.AddressLine1,
+		&addressLine2,
+		&address.City,
+		&address.State,
+		&address.PostalCode,
+		&address.Country,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return address, &checkoutError{"shipping address not found"}
+		}
+		return address, err
+	}
+
+	if addressLine2.Valid {
+		address.AddressLine2 = addressLine2.String
+	}
+
+	return address, nil
+}
+
+// addressFromInput converts an inline shipping address into the stored shape
+func addressFromInput(input models.ShippingAddressInput) models.ShippingAddress {
+	return models.ShippingAddress{
+		RecipientName: input.RecipientName,
+		Phone:         input.Phone,
+		AddressLine1:  input.AddressLine1,
+		AddressLine2:  input.AddressLine2,
+		City:          input.City,
+		State:         input.State,
+		PostalCode:    input.PostalCode,
+		Country:       input.Country,
+		IsDefault:     input.IsDefault,
+	}
+}
+
+// resolveCheckoutAddress returns the address a checkout or quote ships to
+func resolveCheckoutAddress(q queryer, input models.CheckoutInput, userID interface{}) (models.ShippingAddress, error) {
+	if input.ShippingAddressID != nil {
+		return loadShippingAddress(q, *input.ShippingAddressID, userID)
+	}
+	if input.ShippingAddress != nil {
+		return addressFromInput(*input.ShippingAddress), nil
+	}
+	return models.ShippingAddress{}, &checkoutError{"shipping address information is required"}
+}
+
+// priceCart prices a cart in full. Checkout and the quote endpoint both call
+// this, so a quote always matches what the order will be charged.
+func priceCart(q queryer, cartID int, buyer customer, address models.ShippingAddress, shippingMethodID *int, currency models.Currency) (*models.CheckoutQuote, error) {
+	quote, err := priceCartLines(q, cartID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shipping comes before coupons so free-shipping codes know what to discount
+	if err := applyShipping(q, quote, address, shippingMethodID); err != nil {
+		return nil, err
+	}
+
+	if err := applyCartCoupon(q, cartID, buyer, quote); err != nil {
+		return nil, err
+	}
+
+	applyTax(quote)
+
+	quote.GrandTotal = quote.Subtotal - quote.Discount + quote.Shipping
+	if !quote.PricesIncludeTax {
+		quote.GrandTotal += quote.Tax
+	}
+
+	return quote, nil
+}
+
+// priceCartLines prices every line in a cart, in the given currency, before
+// shipping, discounts and tax
+func priceCartLines(q queryer, cartID int, currency models.Currency) (*models.CheckoutQuote, error) {
+	// Checkout prices the cart in its transaction. Reading the lines with
+	// FOR UPDATE there sees the latest stock rather than the transaction's
+	// snapshot, and keeps other checkouts off it until the order is saved.
+	lock := ""
+	if _, ok := q.(*sql.Tx); ok {
+		lock = " FOR UPDATE"
+	}
+
+	rows, err := q.Query(`
+		SELECT ci.product_id, ci.variant_id, COALESCE(v.sku, ''), `+variantTitle+`, ci.quantity, p.name, p.status <> 'published', p.price, pp.price, v.price,
+		       COALESCE(v.stock, p.stock) - `+heldByOtherCarts+`, p.weight, `+taxColumns+`
+		FROM cart_items ci
+		JOIN products p ON ci.product_id = p.id
+		LEFT JOIN product_variants v ON v.id = ci.variant_id
+		LEFT JOIN tax_classes tc ON tc.id = p.tax_class_id
+		`+productPriceJoin+`
+		WHERE ci.cart_id = ?`+lock, utils.Tax.DefaultRate, currency.Code, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quote := &models.CheckoutQuote{
+		Currency:     currency.Code,
+		ExchangeRate: currency.ExchangeRate,
+		Lines:        []models.QuoteLine{},
+		Discounts:    []models.AppliedDiscount{},
+	}
+
+	for rows.Next() {
+		var line models.QuoteLine
+		var variantID sql.NullInt64
+		var unavailable bool
+		var basePrice models.Money
+		var override, variantOverride *models.Money
+
+		err := rows.Scan(
+			&line.ProductID,
+			&variantID,
+			&line.SKU,
+			&line.VariantTitle,
+			&line.Quantity,
+			&line.Name,
+			&unavailable,
+			&basePrice,
+			&override,
+			&variantOverride,
+			&line.CurrentStock,
+			&line.Weight,
+			&line.TaxClass,
+			&line.TaxRate,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		line.VariantID = nullIntPtr(variantID)
+
+		if unavailable {
+			return nil, &checkoutError{fmt.Sprintf("%s is no longer available", line.Name)}
+		}
+
+		// Check stock availability again, leaving out stock other carts are holding
+		if line.Quantity > line.CurrentStock {
+			name := line.Name
+			if line.VariantTitle != "" {
+				name += " (" + line.VariantTitle + ")"
+			}
+			return nil, &checkoutError{fmt.Sprintf("Not enough stock for %s. Available: %d, Requested: %d",
+				name, line.CurrentStock, line.Quantity)}
+		}
+
+		line.UnitPrice = variantPrice(basePrice, override, variantOverride, currency)
+		line.LineTotal = line.UnitPrice.Mul(line.Quantity)
+		quote.Subtotal += line.LineTotal
+		quote.Lines = append(quote.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(quote.Lines) == 0 {
+		return nil, &checkoutError{"cart is empty"}
+	}
+
+	return quote, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"time"
 
 	"goapi/config"//change this to your module
 	"goapi/models"//change this to your module
@@ -11,7 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// CreateProduct adds a new product
+// CreateProduct adds a new product along with its options and variants
 func CreateProduct(c *gin.Context) {
 	var input models.ProductInput
 	
@@ -21,6 +22,21 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 	
+	variants := models.ProductVariantsInput{Options: input.Options, Variants: input.Variants}
+	if msg := validateVariantsInput(&variants); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	
+	// New products stay hidden until they are published
+	if input.Status == "" {
+		input.Status = models.ProductDraft
+	}
+	if msg := validateProductSchedule(&input, time.Now()); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	
 	// Get user ID from context (set by AuthMiddleware)
 	userID, exists := c.Get("userID")
 	if !exists {
@@ -28,9 +44,18 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 	
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	
 	// Insert product into database
-	query := `INSERT INTO products (name, description, price, stock, created_by) VALUES (?, ?, ?, ?, ?)`
-	result, err := config.DB.Exec(query, input.Name, input.Description, input.Price, input.Stock, userID)
+	query := `INSERT INTO products (name, description, price, stock, weight, tax_class_id, status, publish_at, unpublish_at, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, input.Name, input.Description, input.Price, input.Stock, input.Weight, input.TaxClassID,
+		input.Status, input.PublishAt, input.UnpublishAt, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create product"})
 		return
@@ -43,19 +68,65 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 	
+	// Variants replace the stock given for the product with their total
+	if err := saveProductVariants(tx, int(productID), variants); err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sku or barcode is already used by another product"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save product variants"})
+		return
+	}
+	
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+	
+	syncSearchIndex(int(productID))
+	
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "product created successfully",
 		"product_id": productID,
 	})
 }
 
-// GetAllProducts retrieves all products
+// GetAllProducts retrieves a page of products, filtered and sorted by the query parameters
 func GetAllProducts(c *gin.Context) {
-    var products []models.Product
+    products := []models.Product{}
+    
+    // Show prices in the requested currency
+    currency, err := requestCurrency(config.DB, c)
+    if err != nil {
+        writeCheckoutError(c, err, "failed to load currency")
+        return
+    }
+    
+    // Read filters, sorting and pagination
+    list, err := parseProductList(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    
+    // Execute count query for pagination
+    var totalProducts int
+    countQuery, countArgs := list.countQuery(currency)
+    if err := config.DB.QueryRow(countQuery, countArgs...).Scan(&totalProducts); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count products"})
+        return
+    }
     
     // Query products from database
-    query := `SELECT id, name, description, price, stock, created_by, created_at, updated_at FROM products`
-    rows, err := config.DB.Query(query)
+    query, args, err := list.pageQuery(currency,
+        `p.id, p.name, p.description, p.price, p.price_override, p.list_price, p.stock, p.weight, p.tax_class_id, p.created_by, p.created_at, p.updated_at`)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    rows, err := config.DB.Query(query, args...)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch products"})
         return
@@ -63,14 +134,22 @@ func GetAllProducts(c *gin.Context) {
     defer rows.Close()
     
     // Iterate through rows
+    var nextCursor string
+    var lastListPrice models.Money
     for rows.Next() {
         var product models.Product
+        var override *models.Money
+        var listPrice models.Money
         err := rows.Scan(
             &product.ID, 
             &product.Name, 
             &product.Description, 
             &product.Price, 
+            &override, 
+            &listPrice, 
             &product.Stock, 
+            &product.Weight, 
+            &product.TaxClassID, 
             &product.CreatedBy, 
             &product.CreatedAt, 
             &product.UpdatedAt,
@@ -80,49 +159,76 @@ func GetAllProducts(c *gin.Context) {
             return
         }
         
-        // Get sizes for this product
-        sizeRows, err := config.DB.Query(`
-            SELECT ps.id, ps.product_id, ps.size_id, s.name, ps.stock, ps.created_at, ps.updated_at 
-            FROM product_sizes ps
-            JOIN sizes s ON ps.size_id = s.id
-            WHERE ps.product_id = ?
-            ORDER BY s.display_order
-        `, product.ID)
-        
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product sizes"})
-            return
+        // The extra row only tells us there is another page
+        if len(products) == list.limit {
+            nextCursor = list.nextCursor(products[len(products)-1], lastListPrice)
+            break
         }
         
-        var sizes []models.ProductSize
-        for sizeRows.Next() {
-            var size models.ProductSize
-            err := sizeRows.Scan(
-                &size.ID,
-                &size.ProductID,
-                &size.SizeID,
-                &size.SizeName,
-                &size.Stock,
-                &size.CreatedAt,
-                &size.UpdatedAt,
-            )
-            if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process product sizes"})
-                return
-            }
-            sizes = append(sizes, size)
-        }
-        sizeRows.Close()
+        product.Price = localPrice(product.Price, override, currency)
+        product.Currency = currency.Code
         
-        product.Sizes = sizes
         products = append(products, product)
+        lastListPrice = listPrice
+    }
+    rows.Close()
+    
+    // Load the variants, categories and images for the whole page at once
+    productIDs := make([]int, len(products))
+    for i, product := range products {
+        productIDs[i] = product.ID
+    }
+    options, variants, err := loadProductVariants(config.DB, productIDs...)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product variants"})
+        return
+    }
+    categories, err := loadProductCategories(config.DB, productIDs...)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product categories"})
+        return
+    }
+    images, err := loadProductImages(config.DB, productIDs...)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product images"})
+        return
+    }
+    for i := range products {
+        products[i].Options = options[products[i].ID]
+        products[i].Variants = variants[products[i].ID]
+        localVariantPrices(products[i].Variants, currency)
+        products[i].Categories = categories[products[i].ID]
+        products[i].Images = images[products[i].ID]
+    }
+    
+    // Calculate pagination info
+    totalPages := (totalProducts + list.limit - 1) / list.limit
+    pagination := gin.H{
+        "total":       totalProducts,
+        "limit":       list.limit,
+        "total_pages": totalPages,
+    }
+    if list.cursor == nil {
+        pagination["page"] = list.page
+    }
+    if nextCursor != "" {
+        pagination["next_cursor"] = nextCursor
     }
     
-    c.JSON(http.StatusOK, gin.H{"products": products})
+    c.JSON(http.StatusOK, gin.H{
+        "products":   products,
+        "pagination": pagination,
+    })
 }
 
-// GetProduct retrieves a specific product by ID
+// GetProduct retrieves a specific published product by ID
 func GetProduct(c *gin.Context) {
+    showProduct(c, true)
+}
+
+// showProduct writes the product named in the URL, or a 404 when
+// publishedOnly is set and it isn't published
+func showProduct(c *gin.Context, publishedOnly bool) {
     // Get product ID from URL
     productID, err := strconv.Atoi(c.Param("id"))
     if err != nil {
@@ -130,18 +236,38 @@ func GetProduct(c *gin.Context) {
         return
     }
     
+    // Show prices in the requested currency
+    currency, err := requestCurrency(config.DB, c)
+    if err != nil {
+        writeCheckoutError(c, err, "failed to load currency")
+        return
+    }
+    
     // Query product from database
     var product models.Product
-    query := `SELECT id, name, description, price, stock, created_by, created_at, updated_at FROM products WHERE id = ?`
-    err = config.DB.QueryRow(query, productID).Scan(
+    var override *models.Money
+    query := `SELECT p.id, p.name, p.description, p.price, pp.price, p.stock, p.weight, p.tax_class_id, p.created_by, p.created_at, p.updated_at,
+                     p.status, p.publish_at, p.unpublish_at, p.archived_at 
+              FROM products p ` + productPriceJoin + ` WHERE p.id = ?`
+    if publishedOnly {
+        query += ` AND p.status = 'published'`
+    }
+    err = config.DB.QueryRow(query, currency.Code, productID).Scan(
         &product.ID, 
         &product.Name, 
         &product.Description, 
         &product.Price, 
+        &override, 
         &product.Stock, 
+        &product.Weight, 
+        &product.TaxClassID, 
         &product.CreatedBy, 
         &product.CreatedAt, 
         &product.UpdatedAt,
+        &product.Status,
+        &product.PublishAt,
+        &product.UnpublishAt,
+        &product.ArchivedAt,
     )
     
     if err != nil {
@@ -153,46 +279,45 @@ func GetProduct(c *gin.Context) {
         return
     }
     
-    // Get sizes for this product
-    sizeRows, err := config.DB.Query(`
-        SELECT ps.id, ps.product_id, ps.size_id, s.name, ps.stock, ps.created_at, ps.updated_at 
-        FROM product_sizes ps
-        JOIN sizes s ON ps.size_id = s.id
-        WHERE ps.product_id = ?
-        ORDER BY s.display_order
-    `, product.ID)
+    // Get options and variants for this product
+    options, variants, err := loadProductVariants(config.DB, product.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product variants"})
+        return
+    }
+    
+    product.Options = options[product.ID]
+    product.Variants = variants[product.ID]
+    localVariantPrices(product.Variants, currency)
     
+    categories, err := loadProductCategories(config.DB, product.ID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product sizes"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product categories"})
         return
     }
-    defer sizeRows.Close()
-    
-    var sizes []models.ProductSize
-    for sizeRows.Next() {
-        var size models.ProductSize
-        err := sizeRows.Scan(
-            &size.ID,
-            &size.ProductID,
-            &size.SizeID,
-            &size.SizeName,
-            &size.Stock,
-            &size.CreatedAt,
-            &size.UpdatedAt,
-        )
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process product sizes"})
-            return
-        }
-        sizes = append(sizes, size)
+    product.Categories = categories[product.ID]
+    
+    images, err := loadProductImages(config.DB, product.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product images"})
+        return
     }
+    product.Images = images[product.ID]
     
-    product.Sizes = sizes
+    // Include the per-currency overrides so admins can see what is set
+    product.Prices, err = loadProductPrices(config.DB, product.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product prices"})
+        return
+    }
+    product.Price = localPrice(product.Price, override, currency)
+    product.Currency = currency.Code
     
     c.JSON(http.StatusOK, gin.H{"product": product})
 }
 
-// UpdateProduct updates a specific product
+// UpdateProduct updates a specific product. Options and variants are
+// replaced when given and left as they are when omitted.
 func UpdateProduct(c *gin.Context) {
     // Get product ID from URL
     productID, err := strconv.Atoi(c.Param("id"))
@@ -209,65 +334,101 @@ func UpdateProduct(c *gin.Context) {
         return
     }
     
-   // Get user ID from context
-	userID, exists := c.Get("userID")
-	if !exists {
- 	   c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
- 	   return
-	}
-
-	// Check if user is authorized to update this product
-	var isAuthorized bool
-	err = config.DB.QueryRow("SELECT COUNT(*) FROM products WHERE id = ? AND user_id = ?", productID, userID).Scan(&isAuthorized)
-	if err != nil {
- 	   c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
- 	   return
-	}
-
-	if !isAuthorized {
-  	  c.JSON(http.StatusForbidden, gin.H{"error": "not authorized to update this product"})
-  	  return
-	}
+    replaceVariants := input.Options != nil || input.Variants != nil
+    variants := models.ProductVariantsInput{Options: input.Options, Variants: input.Variants}
+    if replaceVariants {
+        if msg := validateVariantsInput(&variants); msg != "" {
+            c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+            return
+        }
+    }
     
-    // Update product in database
-    query := `UPDATE products SET name = ?, description = ?, price = ?, stock = ? WHERE id = ?`
-    _, err = config.DB.Exec(query, input.Name, input.Description, input.Price, input.Stock, productID)
+    // The status and schedule are only changed when a status is given
+    if input.Status != "" {
+        if msg := validateProductSchedule(&input, time.Now()); msg != "" {
+            c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+            return
+        }
+    }
+    
+    // Get user ID from context
+    userID, exists := c.Get("userID")
+    if !exists {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+        return
+    }
+    
+    // Begin transaction
+    tx, err := config.DB.Begin()
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
         return
     }
+    defer tx.Rollback()
     
-    c.JSON(http.StatusOK, gin.H{"message": "product updated successfully"})
-}
-
-// DeleteProduct removes a specific product
-func DeleteProduct(c *gin.Context) {
-    // Get product ID from URL
-    productID, err := strconv.Atoi(c.Param("id"))
+    // Check if user is authorized to update this product
+    var isAuthorized bool
+    var status string
+    err = tx.QueryRow("SELECT created_by = ?, status FROM products WHERE id = ? FOR UPDATE", userID, productID).Scan(&isAuthorized, &status)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+        if err == sql.ErrNoRows {
+            c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+        } else {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+        }
+        return
+    }
+    
+    if !isAuthorized {
+        c.JSON(http.StatusForbidden, gin.H{"error": "not authorized to update this product"})
+        return
+    }
+    
+    if input.Status != "" && status == models.ProductArchived {
+        c.JSON(http.StatusConflict, gin.H{"error": "restore the product before changing its status"})
         return
     }
     
-    // ตรวจสอบเฉพาะว่า product มีอยู่จริงหรือไม่
-    var exists bool
-    err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+    // Update product in database
+    query := `UPDATE products SET name = ?, description = ?, price = ?, stock = ?, weight = ?, tax_class_id = ? WHERE id = ?`
+    _, err = tx.Exec(query, input.Name, input.Description, input.Price, input.Stock, input.Weight, input.TaxClassID, productID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product"})
         return
     }
     
-    if !exists {
-        c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+    if input.Status != "" {
+        _, err = tx.Exec("UPDATE products SET status = ?, publish_at = ?, unpublish_at = ? WHERE id = ?",
+            input.Status, input.PublishAt, input.UnpublishAt, productID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product"})
+            return
+        }
+    }
+    
+    // Products with variants keep the total of their variant stock
+    if replaceVariants {
+        err = saveProductVariants(tx, productID, variants)
+    } else {
+        err = syncProductStock(tx, productID)
+    }
+    if err != nil {
+        if config.IsDuplicateKey(err) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "sku or barcode is already used by another product"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save product variants"})
         return
     }
     
-    // Delete product from database
-    _, err = config.DB.Exec("DELETE FROM products WHERE id = ?", productID)
+    // Commit transaction
+    err = tx.Commit()
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete product"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
         return
     }
     
-    c.JSON(http.StatusOK, gin.H{"message": "product deleted successfully"})
-}
\ No newline at end of file
+    syncSearchIndex(productID)
+    
+    c.JSON(http.StatusOK, gin.H{"message": "product updated successfully"})
+}
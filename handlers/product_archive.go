@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setProductArchived archives or restores a product. Archived products drop
+// out of the catalog and search and can't be added to carts, but orders
+// still refer to them.
+func setProductArchived(c *gin.Context, archive bool) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var archived bool
+	err = config.DB.QueryRow("SELECT archived_at IS NOT NULL FROM products WHERE id = ?", productID).Scan(&archived)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	if archive && archived {
+		c.JSON(http.StatusConflict, gin.H{"error": "product is already archived"})
+		return
+	}
+	if !archive && !archived {
+		c.JSON(http.StatusConflict, gin.H{"error": "product is not archived"})
+		return
+	}
+
+	query := "UPDATE products SET status = 'archived', publish_at = NULL, unpublish_at = NULL, archived_at = NOW() WHERE id = ?"
+	message := "product archived successfully"
+	if !archive {
+		// Restored products come back as drafts to be published again
+		query = "UPDATE products SET status = 'draft', archived_at = NULL WHERE id = ?"
+		message = "product restored successfully"
+	}
+	if _, err := config.DB.Exec(query, productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product"})
+		return
+	}
+
+	syncSearchIndex(productID)
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// ArchiveProduct hides a product from the catalog while keeping it for orders
+func ArchiveProduct(c *gin.Context) {
+	setProductArchived(c, true)
+}
+
+// RestoreProduct takes a product out of the archive as a draft
+func RestoreProduct(c *gin.Context) {
+	setProductArchived(c, false)
+}
+
+// GetArchivedProducts lists archived products for admins, most recently
+// archived first
+func GetArchivedProducts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var total int
+	err := config.DB.QueryRow("SELECT COUNT(*) FROM products WHERE archived_at IS NOT NULL").Scan(&total)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count products"})
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, name, description, price, stock, weight, tax_class_id, created_by, created_at, updated_at, status, archived_at
+		FROM products
+		WHERE archived_at IS NOT NULL
+		ORDER BY archived_at DESC, id DESC
+		LIMIT ? OFFSET ?`, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch products"})
+		return
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Description,
+			&product.Price,
+			&product.Stock,
+			&product.Weight,
+			&product.TaxClassID,
+			&product.CreatedBy,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.Status,
+			&product.ArchivedAt,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process products"})
+			return
+		}
+		products = append(products, product)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (total + limit - 1) / limit,
+		},
+	})
+}
+
+// purgeableProduct matches products p archived for longer than a number of
+// seconds that were never ordered
+const purgeableProduct = `p.status = 'archived' AND p.archived_at < NOW() - INTERVAL ? SECOND
+	  AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.product_id = p.id)`
+
+// PurgeArchivedProducts deletes products archived for longer than olderThan
+// that were never ordered, along with their image files. Products that have
+// been sold are kept for order history. Each product is deleted on its own,
+// so one that can't be deleted is logged and skipped. It returns how many
+// were deleted.
+func PurgeArchivedProducts(olderThan time.Duration) (int, error) {
+	seconds := int(olderThan.Seconds())
+	rows, err := config.DB.Query("SELECT p.id FROM products p WHERE "+purgeableProduct, seconds)
+	if err != nil {
+		return 0, err
+	}
+	var productIDs []int
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, productID := range productIDs {
+		deleted, err := purgeArchivedProduct(productID, seconds)
+		if err != nil {
+			log.Printf("Failed to purge archived product %d: %v", productID, err)
+			continue
+		}
+		if deleted {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeArchivedProduct deletes one product and its image files if it can
+// still be purged, reporting whether it was
+func purgeArchivedProduct(productID, seconds int) (bool, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// It may have been restored or ordered since the batch was picked
+	var id int
+	err = tx.QueryRow("SELECT p.id FROM products p WHERE p.id = ? AND "+purgeableProduct+" FOR UPDATE", productID, seconds).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Note the image files now; their rows go with the product
+	images, err := loadProductImages(tx, productID)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM products WHERE id = ?", productID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	for _, image := range images[productID] {
+		deleteImageFiles(imageFileKeys(image.StorageKey, image.ContentType))
+	}
+
+	return true, nil
+}
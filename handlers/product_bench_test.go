@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goapi/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countingDriver is a fake database that counts queries and answers the
+// ones GetAllProducts runs with made-up rows: a page holds as many products
+// as the query's LIMIT asks for, each with one option, variant, category
+// and image.
+type countingDriver struct {
+	queries int64
+}
+
+var fakeDB = &countingDriver{}
+
+func init() {
+	sql.Register("counting", fakeDB)
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	return &countingConn{d}, nil
+}
+
+type countingConn struct {
+	driver *countingDriver
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return &countingStmt{c.driver, query}, nil
+}
+
+func (c *countingConn) Close() error { return nil }
+
+func (c *countingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type countingStmt struct {
+	driver *countingDriver
+	query  string
+}
+
+func (s *countingStmt) Close() error  { return nil }
+func (s *countingStmt) NumInput() int { return -1 }
+
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("writes are not supported")
+}
+
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(&s.driver.queries, 1)
+	now := time.Now()
+
+	// Queries are told apart by their columns, since the variant title
+	// subquery names the option tables. The IN (...) queries take the page's
+	// product IDs as their arguments.
+	var rows [][]driver.Value
+	switch {
+	case strings.Contains(s.query, "FROM currencies"):
+		rows = [][]driver.Value{{"USD", "US Dollar", "$", 1.0, true, true, now}}
+	case strings.Contains(s.query, "SELECT COUNT(*)"):
+		rows = [][]driver.Value{{int64(1000)}}
+	case strings.Contains(s.query, "LIMIT ? OFFSET ?"):
+		limit := args[len(args)-2].(int64)
+		for id := int64(1); id <= limit; id++ {
+			rows = append(rows, []driver.Value{id, fmt.Sprint("Product ", id), "", "10.00", nil, "10.00", int64(5), 1.0, nil, int64(1), now, now})
+		}
+	case strings.Contains(s.query, "SELECT o.product_id"):
+		for _, id := range args {
+			rows = append(rows, []driver.Value{id, id, "Size", int64(0), id, "M", int64(0)})
+		}
+	case strings.Contains(s.query, "SELECT vv.variant_id"):
+		for _, id := range args {
+			rows = append(rows, []driver.Value{id, id, "M"})
+		}
+	case strings.Contains(s.query, "SELECT v.id, v.product_id"):
+		for _, id := range args {
+			rows = append(rows, []driver.Value{id, id, fmt.Sprint("SKU-", id), nil, nil, int64(5), now, now, "M"})
+		}
+	case strings.Contains(s.query, "SELECT pc.product_id"):
+		for _, id := range args {
+			rows = append(rows, []driver.Value{id, int64(1), "Shirts", "shirts"})
+		}
+	case strings.Contains(s.query, "FROM product_images"):
+		for _, id := range args {
+			rows = append(rows, []driver.Value{id, id, fmt.Sprint("products/", id, ".jpg"), "image/jpeg", int64(800), int64(600), int64(1024), "", int64(0), now})
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query: %s", s.query)
+	}
+	return &countingRows{rows: rows}, nil
+}
+
+type countingRows struct {
+	rows [][]driver.Value
+}
+
+// Columns only needs the right count; the handlers scan by position
+func (r *countingRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *countingRows) Close() error { return nil }
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// BenchmarkGetAllProducts checks that listing products takes the same number
+// of queries whatever the page size, so related rows are loaded per page and
+// not per product. The limits stay within the largest page size, 100.
+func BenchmarkGetAllProducts(b *testing.B) {
+	db, err := sql.Open("counting", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	config.DB = db
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/products", GetAllProducts)
+
+	queries := make(map[int]int64)
+	for _, limit := range []int{1, 20, 100} {
+		b.Run(fmt.Sprint("limit=", limit), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				atomic.StoreInt64(&fakeDB.queries, 0)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprint("/products?limit=", limit), nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", w.Code, w.Body.String())
+				}
+				queries[limit] = atomic.LoadInt64(&fakeDB.queries)
+			}
+		})
+	}
+
+	for limit, count := range queries {
+		if count != queries[1] {
+			b.Fatalf("limit %d took %d queries, limit 1 took %d", limit, count, queries[1])
+		}
+	}
+}
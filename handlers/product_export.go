@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportBatchSize is how many products are read at a time when exporting
+const exportBatchSize = 500
+
+// exportRows turns products into import rows, one per variant, or one for a
+// product without variants, so an export can be edited and imported again.
+// The products' options are returned to keep CSV option pairs in order.
+func exportRows(q queryer, products []models.Product) ([]models.ProductImportRow, map[int][]models.ProductOption, error) {
+	ids := make([]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	options, variants, err := loadProductVariants(q, ids...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []models.ProductImportRow
+	for _, product := range products {
+		productID := product.ID
+		description := product.Description
+		price := product.Price
+		weight := product.Weight
+		base := models.ProductImportRow{
+			ProductID:   &productID,
+			Name:        product.Name,
+			Description: &description,
+			Price:       &price,
+			Weight:      &weight,
+			Status:      product.Status,
+		}
+
+		if len(variants[product.ID]) == 0 {
+			stock := product.Stock
+			row := base
+			row.Stock = &stock
+			rows = append(rows, row)
+			continue
+		}
+
+		for _, variant := range variants[product.ID] {
+			stock := variant.Stock
+			row := base
+			row.SKU = variant.SKU
+			row.Barcode = variant.Barcode
+			row.VariantPrice = variant.Price
+			row.Stock = &stock
+			row.Options = variant.Options
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, options, nil
+}
+
+// formatImportOptions writes variant options as name:value pairs in the
+// product's option order
+func formatImportOptions(values map[string]string, options []models.ProductOption) string {
+	pairs := make([]string, 0, len(values))
+	for _, option := range options {
+		if value, ok := values[option.Name]; ok {
+			pairs = append(pairs, option.Name+":"+value)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ExportProducts downloads the catalog as CSV or JSON (format=csv, the
+// default, or json) in the layout ImportProducts reads. Archived products
+// are left out unless asked for with status.
+func ExportProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+
+	where := "status <> 'archived'"
+	var args []interface{}
+	if status := c.Query("status"); status != "" {
+		where = "status = ?"
+		args = append(args, status)
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT id, name, COALESCE(description, ''), price, stock, weight, status
+		FROM products WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch products"})
+		return
+	}
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Stock, &product.Weight, &product.Status)
+		if err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process products"})
+			return
+		}
+		products = append(products, product)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process products"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="products.`+format+`"`)
+
+	if format == "json" {
+		exported := []models.ProductImportRow{}
+		for start := 0; start < len(products); start += exportBatchSize {
+			batch, _, err := exportRows(config.DB, products[start:min(start+exportBatchSize, len(products))])
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product variants"})
+				return
+			}
+			exported = append(exported, batch...)
+		}
+		c.JSON(http.StatusOK, exported)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(importColumns)
+	for start := 0; start < len(products); start += exportBatchSize {
+		exported, options, err := exportRows(config.DB, products[start:min(start+exportBatchSize, len(products))])
+		if err != nil {
+			// The response has started, so the file is cut short
+			log.Println("Failed to export products:", err)
+			writer.Flush()
+			return
+		}
+
+		for _, row := range exported {
+			barcode, variantPrice := "", ""
+			if row.Barcode != nil {
+				barcode = *row.Barcode
+			}
+			if row.VariantPrice != nil {
+				variantPrice = row.VariantPrice.String()
+			}
+			writer.Write([]string{
+				strconv.Itoa(*row.ProductID),
+				row.SKU,
+				row.Name,
+				*row.Description,
+				row.Price.String(),
+				strconv.FormatFloat(*row.Weight, 'f', -1, 64),
+				row.Status,
+				barcode,
+				variantPrice,
+				strconv.Itoa(*row.Stock),
+				formatImportOptions(row.Options, options[*row.ProductID]),
+			})
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goapi/config"
+	"goapi/models"
+	"goapi/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImagesPerUpload caps how many files one upload request may carry
+const maxImagesPerUpload = 10
+
+// imageURLs fills in an image's URL and thumbnail URLs from its storage key
+func imageURLs(image *models.ProductImage) {
+	image.URL = utils.Files.URL(image.StorageKey)
+	image.Thumbnails = make(map[string]string, len(utils.ThumbnailSizes))
+	for _, size := range utils.ThumbnailSizes {
+		image.Thumbnails[size.Name] = utils.Files.URL(utils.ThumbnailKey(image.StorageKey, image.ContentType, size.Name))
+	}
+}
+
+// imageFileKeys lists the storage keys of an image and all its thumbnails
+func imageFileKeys(key, contentType string) []string {
+	keys := []string{key}
+	for _, size := range utils.ThumbnailSizes {
+		keys = append(keys, utils.ThumbnailKey(key, contentType, size.Name))
+	}
+	return keys
+}
+
+// deleteImageFiles removes stored files. Failures are only logged: the rows
+// are already gone, so an orphaned file is harmless.
+func deleteImageFiles(keys []string) {
+	for _, key := range keys {
+		if err := utils.Files.Delete(context.Background(), key); err != nil {
+			log.Printf("failed to delete image file %s: %v", key, err)
+		}
+	}
+}
+
+// loadProductImages fetches the images of the given products in display
+// order, keyed by product ID
+func loadProductImages(q queryer, productIDs ...int) (map[int][]models.ProductImage, error) {
+	images := make(map[int][]models.ProductImage)
+	if len(productIDs) == 0 {
+		return images, nil
+	}
+
+	placeholders, args := idPlaceholders(productIDs)
+	rows, err := q.Query(`
+		SELECT id, product_id, storage_key, content_type, width, height, size_bytes, alt_text, position, created_at
+		FROM product_images
+		WHERE product_id IN (`+placeholders+`)
+		ORDER BY position, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image models.ProductImage
+		err := rows.Scan(
+			&image.ID,
+			&image.ProductID,
+			&image.StorageKey,
+			&image.ContentType,
+			&image.Width,
+			&image.Height,
+			&image.SizeBytes,
+			&image.AltText,
+			&image.Position,
+			&image.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		imageURLs(&image)
+		images[image.ProductID] = append(images[image.ProductID], image)
+	}
+	return images, rows.Err()
+}
+
+// saveImageFiles stores an image and its thumbnails, returning the keys
+// written. Nothing is left behind when it fails.
+func saveImageFiles(ctx context.Context, key string, image *utils.UploadedImage) ([]string, error) {
+	var saved []string
+
+	if err := utils.Files.Save(ctx, key, bytes.NewReader(image.Data), image.ContentType); err != nil {
+		return nil, err
+	}
+	saved = append(saved, key)
+
+	thumbnailType, _ := utils.ThumbnailType(image.ContentType)
+	for _, size := range utils.ThumbnailSizes {
+		data, err := image.Thumbnail(size.MaxDimension)
+		if err == nil {
+			thumbnailKey := utils.ThumbnailKey(key, image.ContentType, size.Name)
+			err = utils.Files.Save(ctx, thumbnailKey, bytes.NewReader(data), thumbnailType)
+			saved = append(saved, thumbnailKey)
+		}
+		if err != nil {
+			deleteImageFiles(saved)
+			return nil, err
+		}
+	}
+
+	return saved, nil
+}
+
+// UploadProductImages adds images to a product (admin only). Send them as
+// multipart "images" files; an "alt_text" value per file is optional and
+// matched by order. New images go after the existing ones.
+func UploadProductImages(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	// Refuse oversized bodies before reading them
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.Images.MaxBytes*maxImagesPerUpload+1<<20)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart form"})
+		return
+	}
+
+	files := form.File["images"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one image is required"})
+		return
+	}
+	if len(files) > maxImagesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d images can be uploaded at once", maxImagesPerUpload)})
+		return
+	}
+	altTexts := form.Value["alt_text"]
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ?)", productID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	// Store the files one at a time, so only one decoded image is held in
+	// memory, removing them all again if any file is rejected or fails
+	var savedKeys []string
+	committed := false
+	defer func() {
+		if !committed {
+			deleteImageFiles(savedKeys)
+		}
+	}()
+
+	images := make([]models.ProductImage, len(files))
+	for i, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read " + header.Filename})
+			return
+		}
+		upload, err := utils.ReadImage(file)
+		file.Close()
+		if err != nil {
+			var imageErr *utils.ImageError
+			if errors.As(err, &imageErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": header.Filename + ": " + imageErr.Message})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read " + header.Filename})
+			}
+			return
+		}
+
+		key := utils.NewFileKey(fmt.Sprintf("products/%d", productID), utils.ImageTypes[upload.ContentType])
+		keys, err := saveImageFiles(c.Request.Context(), key, upload)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store image"})
+			return
+		}
+		savedKeys = append(savedKeys, keys...)
+
+		images[i] = models.ProductImage{
+			ProductID:   productID,
+			StorageKey:  key,
+			ContentType: upload.ContentType,
+			Width:       upload.Width,
+			Height:      upload.Height,
+			SizeBytes:   len(upload.Data),
+		}
+		if i < len(altTexts) {
+			images[i].AltText = altTexts[i]
+		}
+		upload.Release()
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the product so concurrent uploads don't share positions
+	err = tx.QueryRow("SELECT id FROM products WHERE id = ? FOR UPDATE", productID).Scan(&productID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	var nextPosition int
+	err = tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = ?", productID).Scan(&nextPosition)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	for i, image := range images {
+		_, err = tx.Exec(`
+			INSERT INTO product_images (product_id, storage_key, content_type, width, height, size_bytes, alt_text, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			productID, image.StorageKey, image.ContentType, image.Width, image.Height,
+			image.SizeBytes, image.AltText, nextPosition+i)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+	committed = true
+
+	// Respond with the product's full, ordered image list
+	productImages, err := loadProductImages(config.DB, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch images"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "images uploaded successfully",
+		"images":  productImages[productID],
+	})
+}
+
+// ReorderProductImages sets the display order of a product's images. Every
+// image of the product must be listed exactly once (admin only).
+func ReorderProductImages(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var input models.ProductImageOrderInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM product_images WHERE product_id = ? FOR UPDATE", productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch images"})
+		return
+	}
+
+	current := make(map[int]bool)
+	for rows.Next() {
+		var imageID int
+		if err := rows.Scan(&imageID); err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process images"})
+			return
+		}
+		current[imageID] = false
+	}
+	rows.Close()
+
+	if len(input.ImageIDs) != len(current) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the product exactly once"})
+		return
+	}
+	for _, imageID := range input.ImageIDs {
+		listed, ok := current[imageID]
+		if !ok || listed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the product exactly once"})
+			return
+		}
+		current[imageID] = true
+	}
+
+	for position, imageID := range input.ImageIDs {
+		_, err = tx.Exec("UPDATE product_images SET position = ? WHERE id = ?", position, imageID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reorder images"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "images reordered successfully"})
+}
+
+// UpdateProductImage changes an image's alt text (admin only)
+func UpdateProductImage(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("imageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image ID"})
+		return
+	}
+
+	var input models.ProductImageInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_images WHERE id = ? AND product_id = ?)", imageID, productID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		return
+	}
+
+	_, err = config.DB.Exec("UPDATE product_images SET alt_text = ? WHERE id = ?", input.AltText, imageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update image"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "image updated successfully"})
+}
+
+// DeleteProductImage removes an image and its stored files (admin only)
+func DeleteProductImage(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("imageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image ID"})
+		return
+	}
+
+	var key, contentType string
+	err = config.DB.QueryRow(
+		"SELECT storage_key, content_type FROM product_images WHERE id = ? AND product_id = ?",
+		imageID, productID).Scan(&key, &contentType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	_, err = config.DB.Exec("DELETE FROM product_images WHERE id = ?", imageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete image"})
+		return
+	}
+
+	deleteImageFiles(imageFileKeys(key, contentType))
+
+	c.JSON(http.StatusOK, gin.H{"message": "image deleted successfully"})
+}
@@ -0,0 +1,745 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportBytes   = 20 << 20
+	maxInlineImports = 500 // Larger files are imported in the background
+	skuLookupBatch   = 500 // SKUs looked up per query
+)
+
+// importColumns are the CSV columns of an import or export, in export order
+var importColumns = []string{
+	"product_id", "sku", "name", "description", "price", "weight", "status",
+	"barcode", "variant_price", "stock", "options",
+}
+
+// importRow is a parsed import line with its position in the file and any
+// problem found while reading it
+type importRow struct {
+	models.ProductImportRow
+	line int
+	err  string
+}
+
+// parseImportCSV reads a CSV file with a header row naming importColumns in
+// any order. Options are written as name:value pairs, e.g. "Size:M,Color:Red".
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(importColumns))
+	for _, column := range importColumns {
+		known[column] = true
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("unknown column %q", header[i])
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := importRow{}
+		row.line, _ = reader.FieldPos(0)
+		blank := true
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			blank = false
+			if msg := setImportField(&row.ProductImportRow, header[i], value); msg != "" && row.err == "" {
+				row.err = msg
+			}
+		}
+		if !blank {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// setImportField sets one CSV column on a row, returning a message when the
+// value can't be read
+func setImportField(row *models.ProductImportRow, column, value string) string {
+	switch column {
+	case "product_id":
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return "invalid product_id"
+		}
+		row.ProductID = &id
+	case "sku":
+		row.SKU = value
+	case "name":
+		row.Name = value
+	case "description":
+		row.Description = &value
+	case "price", "variant_price":
+		price, err := models.ParseMoney(value)
+		if err != nil {
+			return "invalid " + column
+		}
+		if column == "price" {
+			row.Price = &price
+		} else {
+			row.VariantPrice = &price
+		}
+	case "weight":
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "invalid weight"
+		}
+		row.Weight = &weight
+	case "status":
+		row.Status = strings.ToLower(value)
+	case "barcode":
+		row.Barcode = &value
+	case "stock":
+		stock, err := strconv.Atoi(value)
+		if err != nil {
+			return "invalid stock"
+		}
+		row.Stock = &stock
+	case "options":
+		row.Options = make(map[string]string)
+		for _, field := range splitList(value) {
+			name, optionValue, ok := strings.Cut(field, ":")
+			name, optionValue = strings.TrimSpace(name), strings.TrimSpace(optionValue)
+			if !ok || name == "" || optionValue == "" {
+				return "options must be name:value pairs"
+			}
+			row.Options[name] = optionValue
+		}
+	}
+	return ""
+}
+
+// parseImportJSON reads a JSON array of rows
+func parseImportJSON(r io.Reader) ([]importRow, error) {
+	var input []models.ProductImportRow
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, len(input))
+	for i, row := range input {
+		row.SKU = strings.TrimSpace(row.SKU)
+		row.Name = strings.TrimSpace(row.Name)
+		row.Status = strings.ToLower(strings.TrimSpace(row.Status))
+		rows[i] = importRow{ProductImportRow: row, line: i + 1}
+	}
+	return rows, nil
+}
+
+// importGroup is the rows of one product in an import. productID is zero for
+// a product the import creates.
+type importGroup struct {
+	productID int
+	rows      []importRow
+}
+
+// skuProducts finds the products that already have the given SKUs, keyed by
+// lowercased SKU since SKUs are matched case-insensitively
+func skuProducts(q queryer, skus []string) (map[string]int, error) {
+	products := make(map[string]int)
+	for start := 0; start < len(skus); start += skuLookupBatch {
+		placeholders, args := idPlaceholders(skus[start:min(start+skuLookupBatch, len(skus))])
+		rows, err := q.Query("SELECT sku, product_id FROM product_variants WHERE sku IN ("+placeholders+")", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var sku string
+			var productID int
+			if err := rows.Scan(&sku, &productID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			products[strings.ToLower(sku)] = productID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return products, nil
+}
+
+// groupImportRows puts rows together by product: the product_id given, else
+// the product that already has the SKU, else the name of a new product.
+// Rows that can't be placed are reported.
+func groupImportRows(q queryer, rows []importRow, report *models.ProductImportReport) ([]*importGroup, error) {
+	// A SKU may only be on one row. Checked across the whole file because a
+	// dry run rolls each product back and so can't catch two products
+	// claiming the same SKU.
+	skuRows := make(map[string]int)
+	var skus []string
+	for _, row := range rows {
+		if row.SKU == "" {
+			continue
+		}
+		key := strings.ToLower(row.SKU)
+		if skuRows[key] == 0 {
+			skus = append(skus, row.SKU)
+		}
+		skuRows[key]++
+	}
+
+	existing, err := skuProducts(q, skus)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*importGroup
+	byKey := make(map[string]*importGroup)
+
+	for _, row := range rows {
+		skuProductID := existing[strings.ToLower(row.SKU)]
+		if skuRows[strings.ToLower(row.SKU)] > 1 && row.err == "" {
+			row.err = fmt.Sprintf("sku %s is on more than one row", row.SKU)
+		}
+
+		var key string
+		productID := 0
+		switch {
+		case row.ProductID != nil:
+			productID = *row.ProductID
+			if skuProductID != 0 && skuProductID != productID && row.err == "" {
+				row.err = fmt.Sprintf("sku %s belongs to product %d", row.SKU, skuProductID)
+			}
+			key = "id:" + strconv.Itoa(productID)
+		case skuProductID != 0:
+			productID = skuProductID
+			key = "id:" + strconv.Itoa(productID)
+		case row.Name != "":
+			key = "new:" + strings.ToLower(row.Name)
+		default:
+			report.Errors = append(report.Errors, models.ProductImportError{
+				Row: row.line, SKU: row.SKU, Error: "name is required for a new product"})
+			continue
+		}
+
+		group, ok := byKey[key]
+		if !ok {
+			group = &importGroup{productID: productID}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.rows = append(group.rows, row)
+	}
+
+	return groups, nil
+}
+
+// addImportOption adds an option value to the options being imported,
+// matching names and values case-insensitively
+func addImportOption(input *models.ProductVariantsInput, name, value string) {
+	for i := range input.Options {
+		option := &input.Options[i]
+		if !strings.EqualFold(option.Name, name) {
+			continue
+		}
+		for _, existing := range option.Values {
+			if strings.EqualFold(existing, value) {
+				return
+			}
+		}
+		option.Values = append(option.Values, value)
+		return
+	}
+	input.Options = append(input.Options, models.ProductOptionInput{Name: name, Values: []string{value}})
+}
+
+// importProduct writes one product's rows. Product fields come from the
+// first row; variants are matched by SKU and those not in the file are kept.
+// A message is returned for rows that can't be imported, an error when the
+// database fails.
+func importProduct(tx *sql.Tx, group *importGroup, createdBy int, report *models.ProductImportReport) (int, string, error) {
+	first := group.rows[0]
+	for _, row := range group.rows {
+		if row.err != "" {
+			return 0, row.err, nil
+		}
+		if row.Stock != nil && *row.Stock < 0 {
+			return 0, "stock cannot be negative", nil
+		}
+	}
+
+	if first.Price != nil && *first.Price <= 0 {
+		return 0, "price must be greater than 0", nil
+	}
+	if first.Weight != nil && *first.Weight < 0 {
+		return 0, "weight cannot be negative", nil
+	}
+
+	productID := group.productID
+	var status string
+	var options map[int][]models.ProductOption
+	var variants map[int][]models.ProductVariant
+	if productID > 0 {
+		err := tx.QueryRow("SELECT status FROM products WHERE id = ? FOR UPDATE", productID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return 0, "product not found", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		if status == models.ProductArchived {
+			return 0, "product is archived", nil
+		}
+
+		options, variants, err = loadProductVariants(tx, productID)
+		if err != nil {
+			return 0, "", err
+		}
+	} else if first.Price == nil {
+		return 0, "price is required for a new product", nil
+	}
+
+	// The status may be left as exported, otherwise only draft or published
+	// can be set
+	if first.Status != "" && first.Status != status && first.Status != models.ProductDraft && first.Status != models.ProductPublished {
+		return 0, "status must be draft or published", nil
+	}
+
+	// A row without a SKU is a product sold without variants
+	withoutVariants := first.SKU == ""
+	for _, row := range group.rows {
+		if (row.SKU == "") != withoutVariants || (withoutVariants && len(group.rows) > 1) {
+			return 0, "a product without variants must be a single row without a sku", nil
+		}
+	}
+	if withoutVariants && len(first.Options) > 0 {
+		return 0, "options need a sku", nil
+	}
+	if withoutVariants && len(variants[productID]) > 0 {
+		return 0, "sku is required for a product with variants", nil
+	}
+
+	// Start from the saved options and variants and lay the rows over them
+	input := models.ProductVariantsInput{}
+	for _, option := range options[productID] {
+		optionInput := models.ProductOptionInput{Name: option.Name}
+		for _, value := range option.Values {
+			optionInput.Values = append(optionInput.Values, value.Value)
+		}
+		input.Options = append(input.Options, optionInput)
+	}
+	bySKU := make(map[string]int)
+	for _, variant := range variants[productID] {
+		bySKU[variant.SKU] = len(input.Variants)
+		input.Variants = append(input.Variants, models.ProductVariantInput{
+			SKU:     variant.SKU,
+			Barcode: variant.Barcode,
+			Price:   variant.Price,
+			Stock:   variant.Stock,
+			Options: variant.Options,
+		})
+	}
+	existing := len(input.Variants)
+
+	var created, updated int
+	touched := make(map[int]bool)
+	if !withoutVariants {
+		for _, row := range group.rows {
+			names := make([]string, 0, len(row.Options))
+			for name := range row.Options {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				addImportOption(&input, name, row.Options[name])
+			}
+
+			i, ok := bySKU[row.SKU]
+			if !ok {
+				i = len(input.Variants)
+				bySKU[row.SKU] = i
+				input.Variants = append(input.Variants, models.ProductVariantInput{SKU: row.SKU, Options: map[string]string{}})
+				created++
+			} else if i < existing && !touched[i] {
+				updated++
+			}
+			touched[i] = true
+
+			variant := &input.Variants[i]
+			if row.Barcode != nil {
+				variant.Barcode = row.Barcode
+			}
+			if row.VariantPrice != nil {
+				variant.Price = row.VariantPrice
+			}
+			if row.Stock != nil {
+				variant.Stock = *row.Stock
+			}
+			if row.Options != nil {
+				variant.Options = row.Options
+			}
+		}
+
+		if msg := validateVariantsInput(&input); msg != "" {
+			return 0, msg, nil
+		}
+	}
+
+	if productID == 0 {
+		description, status, stock := "", models.ProductDraft, 0
+		if first.Description != nil {
+			description = *first.Description
+		}
+		if first.Status != "" {
+			status = first.Status
+		}
+		if first.Stock != nil {
+			stock = *first.Stock
+		}
+		weight := 0.0
+		if first.Weight != nil {
+			weight = *first.Weight
+		}
+
+		result, err := tx.Exec(`INSERT INTO products (name, description, price, stock, weight, status, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			first.Name, description, *first.Price, stock, weight, status, createdBy)
+		if err != nil {
+			return 0, "", err
+		}
+		newProductID, err := result.LastInsertId()
+		if err != nil {
+			return 0, "", err
+		}
+		productID = int(newProductID)
+		report.ProductsCreated++
+	} else {
+		_, err := tx.Exec(`
+			UPDATE products
+			SET name = COALESCE(?, name), description = COALESCE(?, description), price = COALESCE(?, price),
+			    weight = COALESCE(?, weight), stock = COALESCE(?, stock)
+			WHERE id = ?`,
+			nullIfEmpty(first.Name), first.Description, first.Price, first.Weight, first.Stock, productID)
+		if err != nil {
+			return 0, "", err
+		}
+
+		// Changing the status drops any schedule, as it does in UpdateProduct
+		if first.Status != "" && first.Status != status {
+			schedule := models.ProductInput{Status: first.Status}
+			validateProductSchedule(&schedule, time.Now())
+			_, err = tx.Exec("UPDATE products SET status = ?, publish_at = ?, unpublish_at = ? WHERE id = ?",
+				schedule.Status, schedule.PublishAt, schedule.UnpublishAt, productID)
+			if err != nil {
+				return 0, "", err
+			}
+		}
+		report.ProductsUpdated++
+	}
+
+	if !withoutVariants {
+		if err := saveProductVariants(tx, productID, input); err != nil {
+			if config.IsDuplicateKey(err) {
+				return 0, "sku or barcode is already used by another product", nil
+			}
+			return 0, "", err
+		}
+	}
+
+	report.VariantsCreated += created
+	report.VariantsUpdated += updated
+	return productID, "", nil
+}
+
+// runProductImport imports rows product by product, each in its own
+// transaction, so one bad product doesn't hold up the rest. A dry run rolls
+// every product back and only reports.
+func runProductImport(rows []importRow, dryRun bool, createdBy int) (*models.ProductImportReport, error) {
+	report := &models.ProductImportReport{Rows: len(rows), Errors: []models.ProductImportError{}}
+
+	groups, err := groupImportRows(config.DB, rows, report)
+	if err != nil {
+		return report, err
+	}
+
+	for _, group := range groups {
+		tx, err := config.DB.Begin()
+		if err != nil {
+			return report, err
+		}
+
+		// Counts only stand if the product goes through
+		counts := *report
+		productID, msg, err := importProduct(tx, group, createdBy, &counts)
+		if err != nil || msg != "" || dryRun {
+			tx.Rollback()
+		}
+		if err != nil {
+			return report, err
+		}
+		if msg != "" {
+			first := group.rows[0]
+			report.Errors = append(report.Errors, models.ProductImportError{
+				Row: first.line, SKU: first.SKU, Name: first.Name, Error: msg})
+			continue
+		}
+		report.ProductsCreated, report.ProductsUpdated = counts.ProductsCreated, counts.ProductsUpdated
+		report.VariantsCreated, report.VariantsUpdated = counts.VariantsCreated, counts.VariantsUpdated
+		if dryRun {
+			continue
+		}
+
+		if err := tx.Commit(); err != nil {
+			return report, err
+		}
+		syncSearchIndex(productID)
+	}
+
+	return report, nil
+}
+
+// finishProductImport records the outcome of an import
+func finishProductImport(importID int, report *models.ProductImportReport, importErr error) {
+	status, message := models.ImportDone, ""
+	if importErr != nil {
+		log.Printf("Product import %d failed: %v", importID, importErr)
+		status, message = models.ImportFailed, "import stopped by an internal error"
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Failed to encode report for product import %d: %v", importID, err)
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE product_imports SET status = ?, report = ?, error = ?, finished_at = NOW()
+		WHERE id = ?`, status, string(reportJSON), nullIfEmpty(message), importID)
+	if err != nil {
+		log.Printf("Failed to record product import %d: %v", importID, err)
+	}
+}
+
+// FailInterruptedImports marks imports left queued or running by a restart
+// as failed, since nothing will pick them up again. It returns how many were
+// marked.
+func FailInterruptedImports() (int, error) {
+	result, err := config.DB.Exec(`
+		UPDATE product_imports SET status = ?, error = ?, finished_at = NOW()
+		WHERE status IN (?, ?)`,
+		models.ImportFailed, "import interrupted by a server restart", models.ImportQueued, models.ImportRunning)
+	if err != nil {
+		return 0, err
+	}
+	failed, err := result.RowsAffected()
+	return int(failed), err
+}
+
+// loadProductImport fetches an import with its report
+func loadProductImport(q queryer, importID int) (models.ProductImport, error) {
+	var productImport models.ProductImport
+	var report, message sql.NullString
+	err := q.QueryRow(`
+		SELECT id, format, dry_run, status, report, error, created_by, created_at, finished_at
+		FROM product_imports WHERE id = ?`, importID).Scan(
+		&productImport.ID,
+		&productImport.Format,
+		&productImport.DryRun,
+		&productImport.Status,
+		&report,
+		&message,
+		&productImport.CreatedBy,
+		&productImport.CreatedAt,
+		&productImport.FinishedAt,
+	)
+	if err != nil {
+		return productImport, err
+	}
+
+	productImport.Error = message.String
+	if report.Valid {
+		productImport.Report = &models.ProductImportReport{}
+		if err := json.Unmarshal([]byte(report.String), productImport.Report); err != nil {
+			return productImport, err
+		}
+	}
+	return productImport, nil
+}
+
+// ImportProducts imports products, variants and stock from a CSV or JSON
+// file, sent as a multipart "file" or as the request body. Pass format=csv
+// or json when it can't be told from the file name or content type, and
+// dry_run=true to only validate. Large files are imported in the background;
+// poll GetProductImport for the report.
+func ImportProducts(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	format := strings.ToLower(c.Query("format"))
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer file.Close()
+		body = file
+
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = "csv"
+		case "application/json":
+			format = "json"
+		}
+	}
+
+	var rows []importRow
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(body)
+	case "json":
+		rows, err = parseImportJSON(body)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read " + format + ": " + err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the file has no rows"})
+		return
+	}
+
+	background := len(rows) > maxInlineImports
+	status := models.ImportRunning
+	if background {
+		status = models.ImportQueued
+	}
+	result, err := config.DB.Exec("INSERT INTO product_imports (format, dry_run, status, created_by) VALUES (?, ?, ?, ?)",
+		format, dryRun, status, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start import"})
+		return
+	}
+	newImportID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start import"})
+		return
+	}
+	importID := int(newImportID)
+
+	if background {
+		go func() {
+			// A panic would otherwise leave the import running forever
+			defer func() {
+				if r := recover(); r != nil {
+					report := &models.ProductImportReport{Rows: len(rows), Errors: []models.ProductImportError{}}
+					finishProductImport(importID, report, fmt.Errorf("panic: %v", r))
+				}
+			}()
+
+			if _, err := config.DB.Exec("UPDATE product_imports SET status = ? WHERE id = ?", models.ImportRunning, importID); err != nil {
+				log.Printf("Failed to start product import %d: %v", importID, err)
+			}
+			report, err := runProductImport(rows, dryRun, userID.(int))
+			finishProductImport(importID, report, err)
+		}()
+
+		productImport, err := loadProductImport(config.DB, importID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load import"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "import started", "import": productImport})
+		return
+	}
+
+	report, err := runProductImport(rows, dryRun, userID.(int))
+	finishProductImport(importID, report, err)
+
+	productImport, err := loadProductImport(config.DB, importID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load import"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"import": productImport})
+}
+
+// GetProductImport returns an import's status and report
+func GetProductImport(c *gin.Context) {
+	// Get import ID from URL
+	importID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import ID"})
+		return
+	}
+
+	productImport, err := loadProductImport(config.DB, importID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load import"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"import": productImport})
+}
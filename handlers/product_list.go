@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// productListFrom wraps products with their price in the request currency as
+// list_price, so filters, sorting and cursors all see the price shown.
+// Only published products are listed.
+// Pass the exchange rate and currency code as the query arguments.
+const productListFrom = `
+	FROM (
+		SELECT p.*, pp.price AS price_override, COALESCE(pp.price, ROUND(p.price * ?, 2)) AS list_price
+		FROM products p ` + productPriceJoin + `
+		WHERE p.status = 'published'
+	) p`
+
+// productSort is one way of ordering the catalog. Ties are broken by ID so
+// cursors always land on a single row.
+type productSort struct {
+	column string
+	desc   bool
+}
+
+var productSorts = map[string]productSort{
+	"newest":     {"p.created_at", true},
+	"oldest":     {"p.created_at", false},
+	"price_asc":  {"p.list_price", false},
+	"price_desc": {"p.list_price", true},
+	"name_asc":   {"p.name", false},
+	"name_desc":  {"p.name", true},
+}
+
+// productCursor marks the last product of a page: its sort value and ID
+type productCursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    int             `json:"id"`
+}
+
+// productList is a parsed catalog request: filters, sort order and either a
+// page number or a cursor
+type productList struct {
+	conditions []string
+	args       []interface{}
+	sort       productSort
+	page       int
+	limit      int
+	cursor     *productCursor
+}
+
+// parseProductList reads the catalog query parameters: page or cursor, limit,
+// min_price, max_price, in_stock, options (name:value pairs), category
+// (comma separated), created_by and sort
+func parseProductList(c *gin.Context) (*productList, error) {
+	list := &productList{sort: productSorts["newest"]}
+
+	list.page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	list.limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if list.page < 1 {
+		list.page = 1
+	}
+	if list.limit < 1 || list.limit > 100 {
+		list.limit = 20
+	}
+
+	if sortName := c.Query("sort"); sortName != "" {
+		sort, ok := productSorts[sortName]
+		if !ok {
+			return nil, errors.New("sort must be one of newest, oldest, price_asc, price_desc, name_asc, name_desc")
+		}
+		list.sort = sort
+	}
+
+	// Prices are in the request currency
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		price, err := models.ParseMoney(minPrice)
+		if err != nil {
+			return nil, errors.New("invalid min_price")
+		}
+		list.where("p.list_price >= ?", price)
+	}
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		price, err := models.ParseMoney(maxPrice)
+		if err != nil {
+			return nil, errors.New("invalid max_price")
+		}
+		list.where("p.list_price <= ?", price)
+	}
+
+	if inStock := c.Query("in_stock"); inStock != "" {
+		wantStock, err := strconv.ParseBool(inStock)
+		if err != nil {
+			return nil, errors.New("invalid in_stock")
+		}
+		if wantStock {
+			list.where("p.stock > 0")
+		} else {
+			list.where("p.stock <= 0")
+		}
+	}
+
+	// Products with an in-stock variant matching every option, and any of
+	// the values given for each option
+	if value := c.Query("options"); value != "" {
+		filters, err := parseOptionFilters(value)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make([]string, 0, len(filters))
+		for name := range filters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var conditions []string
+		var args []interface{}
+		for _, name := range names {
+			placeholders := make([]string, len(filters[name]))
+			args = append(args, name)
+			for i, optionValue := range filters[name] {
+				placeholders[i] = "?"
+				args = append(args, optionValue)
+			}
+			conditions = append(conditions, `EXISTS (
+				SELECT 1 FROM product_variant_values vv
+				JOIN product_option_values ov ON ov.id = vv.option_value_id
+				JOIN product_options o ON o.id = ov.option_id
+				WHERE vv.variant_id = v.id AND LOWER(o.name) = ? AND ov.value IN (`+strings.Join(placeholders, ", ")+`))`)
+		}
+		list.where(`EXISTS (
+			SELECT 1 FROM product_variants v
+			WHERE v.product_id = p.id AND v.stock > 0 AND `+strings.Join(conditions, " AND ")+`)`, args...)
+	}
+
+	// Products in any of the categories or their subcategories
+	if slugs := splitList(c.Query("category")); len(slugs) > 0 {
+		placeholders := make([]string, len(slugs))
+		args := make([]interface{}, len(slugs))
+		for i, slug := range slugs {
+			placeholders[i] = "?"
+			args[i] = slug
+		}
+		list.where(`EXISTS (
+			SELECT 1 FROM product_categories pc
+			WHERE pc.product_id = p.id AND pc.category_id IN (`+fmt.Sprintf(categoryTreeQuery, strings.Join(placeholders, ", "))+`))`, args...)
+	}
+
+	if createdBy := c.Query("created_by"); createdBy != "" {
+		userID, err := strconv.Atoi(createdBy)
+		if err != nil {
+			return nil, errors.New("invalid created_by")
+		}
+		list.where("p.created_by = ?", userID)
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		list.cursor = &productCursor{}
+		if err := json.Unmarshal(raw, list.cursor); err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+	}
+
+	return list, nil
+}
+
+// where adds a filter condition with its arguments
+func (l *productList) where(condition string, args ...interface{}) {
+	l.conditions = append(l.conditions, condition)
+	l.args = append(l.args, args...)
+}
+
+// whereClause joins the given conditions into a WHERE clause
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// countQuery counts every product matching the filters
+func (l *productList) countQuery(currency models.Currency) (string, []interface{}) {
+	args := append([]interface{}{currency.ExchangeRate, currency.Code}, l.args...)
+	return `SELECT COUNT(*) ` + productListFrom + whereClause(l.conditions), args
+}
+
+// pageQuery selects one page of matching products, plus one extra row to
+// tell whether there is a next page
+func (l *productList) pageQuery(currency models.Currency, columns string) (string, []interface{}, error) {
+	args := append([]interface{}{currency.ExchangeRate, currency.Code}, l.args...)
+	conditions := append([]string{}, l.conditions...)
+
+	direction, after := "ASC", ">"
+	if l.sort.desc {
+		direction, after = "DESC", "<"
+	}
+
+	offset := (l.page - 1) * l.limit
+	if l.cursor != nil {
+		value, err := l.cursorValue()
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "("+l.sort.column+" "+after+" ? OR ("+l.sort.column+" = ? AND p.id "+after+" ?))")
+		args = append(args, value, value, l.cursor.ID)
+		offset = 0
+	}
+
+	query := `SELECT ` + columns + productListFrom + whereClause(conditions) +
+		` ORDER BY ` + l.sort.column + ` ` + direction + `, p.id ` + direction + ` LIMIT ? OFFSET ?`
+	args = append(args, l.limit+1, offset)
+	return query, args, nil
+}
+
+// cursorValue decodes the cursor's sort value into the type of the sort column
+func (l *productList) cursorValue() (interface{}, error) {
+	var value interface{}
+	var err error
+
+	switch l.sort.column {
+	case "p.created_at":
+		var createdAt time.Time
+		err = json.Unmarshal(l.cursor.Value, &createdAt)
+		value = createdAt
+	case "p.list_price":
+		var price models.Money
+		err = json.Unmarshal(l.cursor.Value, &price)
+		value = price
+	default:
+		var name string
+		err = json.Unmarshal(l.cursor.Value, &name)
+		value = name
+	}
+	if err != nil {
+		return nil, errors.New("cursor does not match sort")
+	}
+	return value, nil
+}
+
+// nextCursor builds the cursor for the page after the given product
+func (l *productList) nextCursor(product models.Product, listPrice models.Money) string {
+	var value interface{}
+	switch l.sort.column {
+	case "p.created_at":
+		value = product.CreatedAt
+	case "p.list_price":
+		value = listPrice
+	default:
+		value = product.Name
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	raw, err := json.Marshal(productCursor{Value: encoded, ID: product.ID})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"time"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateProductSchedule checks the publish and unpublish times fit the
+// status, clearing those that don't apply
+func validateProductSchedule(input *models.ProductInput, now time.Time) string {
+	switch input.Status {
+	case models.ProductScheduled:
+		if input.PublishAt == nil {
+			return "publish_at is required for scheduled products"
+		}
+		if !input.PublishAt.After(now) {
+			return "publish_at must be in the future"
+		}
+	case models.ProductDraft:
+		input.PublishAt = nil
+		input.UnpublishAt = nil
+	default:
+		input.PublishAt = nil
+	}
+
+	if input.UnpublishAt != nil {
+		if !input.UnpublishAt.After(now) {
+			return "unpublish_at must be in the future"
+		}
+		if input.PublishAt != nil && !input.UnpublishAt.After(*input.PublishAt) {
+			return "unpublish_at must be after publish_at"
+		}
+	}
+
+	return ""
+}
+
+// scheduledProductIDs returns the products whose status is due to change
+func scheduledProductIDs(q queryer, query string) ([]int, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var productIDs []int
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	return productIDs, rows.Err()
+}
+
+// ApplyProductSchedules publishes scheduled products whose publish_at has
+// passed and returns published products whose unpublish_at has passed to
+// draft. It returns how many products were published and unpublished.
+func ApplyProductSchedules() (int, int, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	published, err := scheduledProductIDs(tx, `
+		SELECT id FROM products
+		WHERE status = 'scheduled' AND publish_at <= NOW()
+		FOR UPDATE`)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(published) > 0 {
+		placeholders, args := idPlaceholders(published)
+		_, err := tx.Exec("UPDATE products SET status = 'published', publish_at = NULL WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	unpublished, err := scheduledProductIDs(tx, `
+		SELECT id FROM products
+		WHERE status = 'published' AND unpublish_at <= NOW()
+		FOR UPDATE`)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(unpublished) > 0 {
+		placeholders, args := idPlaceholders(unpublished)
+		_, err := tx.Exec("UPDATE products SET status = 'draft', unpublish_at = NULL WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, productID := range append(published, unpublished...) {
+		syncSearchIndex(productID)
+	}
+
+	return len(published), len(unpublished), nil
+}
+
+// PreviewProduct shows admins a product whatever its status
+func PreviewProduct(c *gin.Context) {
+	showProduct(c, false)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReorderOrder copies a past order's lines back into the user's cart. Each
+// line goes through the same checks as AddToCart; lines that no longer fit
+// the stock are reduced, lines that can't be added at all are skipped, and
+// lines whose price has changed since the order are flagged.
+func ReorderOrder(c *gin.Context) {
+	// Get order ID (or receipt order number) from URL
+	orderID := c.Param("id")
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	var dbOrderID int
+	var currencyCode string
+	err := config.DB.QueryRow(`
+		SELECT id, currency FROM orders
+		WHERE (order_id = ? OR order_number = ?) AND user_id = ?`,
+		orderID, orderID, userID).Scan(&dbOrderID, &currencyCode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	// Prices are compared in the currency the order was paid in
+	currency, err := scanCurrency(config.DB.QueryRow(
+		`SELECT `+currencyColumns+` FROM currencies WHERE code = ?`, currencyCode))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load currency"})
+		return
+	}
+	knownCurrency := err == nil
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT oi.product_id, oi.variant_id, COALESCE(oi.variant_title, ''), oi.quantity, oi.price,
+		       p.id IS NOT NULL AND p.status = 'published', COALESCE(p.name, ''), COALESCE(p.price, 0), pp.price, v.price
+		FROM order_items oi
+		LEFT JOIN products p ON p.id = oi.product_id
+		LEFT JOIN product_variants v ON v.id = oi.variant_id
+		`+productPriceJoin+`
+		WHERE oi.order_id = ?
+		ORDER BY oi.id`,
+		currencyCode, dbOrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch order items"})
+		return
+	}
+
+	type orderedLine struct {
+		line      models.ReorderLine
+		available bool
+		basePrice models.Money
+		override  *models.Money
+		variant   *models.Money
+	}
+
+	var ordered []orderedLine
+	for rows.Next() {
+		var o orderedLine
+		var variantID sql.NullInt64
+
+		err := rows.Scan(
+			&o.line.ProductID,
+			&variantID,
+			&o.line.VariantTitle,
+			&o.line.RequestedQuantity,
+			&o.line.OrderedPrice,
+			&o.available,
+			&o.line.Name,
+			&o.basePrice,
+			&o.override,
+			&o.variant,
+		)
+		if err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order items"})
+			return
+		}
+
+		o.line.VariantID = nullIntPtr(variantID)
+		ordered = append(ordered, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order items"})
+		return
+	}
+
+	cartID, err := findOrCreateCart(tx, c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find cart"})
+		return
+	}
+
+	lines := []models.ReorderLine{}
+	for _, o := range ordered {
+		line := o.line
+
+		if !o.available {
+			line.Status = models.ReorderSkipped
+			line.Reason = "product is no longer available"
+			lines = append(lines, line)
+			continue
+		}
+
+		if knownCurrency {
+			line.CurrentPrice = variantPrice(o.basePrice, o.override, o.variant, currency)
+			line.PriceChanged = line.CurrentPrice != line.OrderedPrice
+		}
+
+		variantID := 0
+		if line.VariantID != nil {
+			variantID = *line.VariantID
+		}
+
+		// Fit the line into whatever stock is left after what's already in the cart
+		if err := lockProductStock(tx, line.ProductID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		available, err := cartStock(tx, line.ProductID, variantID, cartID)
+		if err == nil {
+			var inCart int
+			err = tx.QueryRow("SELECT COALESCE(SUM(quantity), 0) FROM cart_items WHERE cart_id = ? AND product_id = ? AND variant_id <=> ?",
+				cartID, line.ProductID, line.VariantID).Scan(&inCart)
+			available -= inCart
+		}
+
+		line.Quantity = line.RequestedQuantity
+		if err == nil && available < line.Quantity {
+			line.Quantity = available
+		}
+
+		if err == nil && line.Quantity < 1 {
+			err = &cartItemError{http.StatusBadRequest, "not enough stock available"}
+		}
+		if err == nil {
+			_, err = addCartItem(tx, c, line.ProductID, variantID, line.Quantity)
+		}
+
+		var itemErr *cartItemError
+		switch {
+		case errors.As(err, &itemErr):
+			line.Quantity = 0
+			line.Status = models.ReorderSkipped
+			line.Reason = itemErr.message
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add order items to cart"})
+			return
+		case line.Quantity < line.RequestedQuantity:
+			line.Status = models.ReorderReduced
+			line.Reason = "not enough stock available"
+		default:
+			line.Status = models.ReorderAdded
+		}
+
+		lines = append(lines, line)
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "order items added to cart",
+		"lines":   lines,
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"goapi/utils"
+)
+
+// heldByOtherCarts sums the live holds other carts have on a cart line's
+// product and variant, for use in a query over cart_items ci and products p
+const heldByOtherCarts = `COALESCE((
+		SELECT SUM(r.quantity) FROM stock_reservations r
+		WHERE r.product_id = p.id AND r.variant_id <=> ci.variant_id AND r.cart_id <> ci.cart_id AND r.expires_at > NOW()), 0)`
+
+// heldStock returns how much of a product other carts are holding. A variant
+// ID of zero counts holds across every variant of the product.
+func heldStock(q queryer, productID, variantID, cartID int) (int, error) {
+	if !utils.Reservations.Enabled() {
+		return 0, nil
+	}
+
+	query := `
+		SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations
+		WHERE product_id = ? AND cart_id <> ? AND expires_at > NOW()`
+	args := []interface{}{productID, cartID}
+	if variantID > 0 {
+		query += ` AND variant_id = ?`
+		args = append(args, variantID)
+	}
+
+	var held int
+	err := q.QueryRow(query, args...).Scan(&held)
+	return held, err
+}
+
+// reserveCartItem holds stock for a cart item, restarting its reservation window
+func reserveCartItem(q queryer, cartItemID int) error {
+	if !utils.Reservations.Enabled() {
+		return nil
+	}
+
+	_, err := q.Exec(`
+		INSERT INTO stock_reservations (cart_item_id, cart_id, product_id, variant_id, quantity, expires_at)
+		SELECT id, cart_id, product_id, variant_id, quantity, NOW() + INTERVAL ? SECOND
+		FROM cart_items WHERE id = ?
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), expires_at = VALUES(expires_at)`,
+		int(utils.Reservations.Window.Seconds()), cartItemID)
+	return err
+}
+
+// lockProductStock locks a product row while holds are in use, so two carts
+// can't both claim the last units at the same time
+func lockProductStock(q queryer, productID int) error {
+	if !utils.Reservations.Enabled() {
+		return nil
+	}
+
+	var id int
+	return q.QueryRow("SELECT id FROM products WHERE id = ? FOR UPDATE", productID).Scan(&id)
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// savedList returns the list named in the URL, reporting a 404 for unknown lists
+func savedList(c *gin.Context) (string, bool) {
+	list := c.Param("list")
+	if list != models.ListWishlist && list != models.ListSaveForLater {
+		c.JSON(http.StatusNotFound, gin.H{"error": "list not found"})
+		return "", false
+	}
+	return list, true
+}
+
+// saveItem puts a product (and variant) on a user's list at its current price.
+// Saving something already on the save-for-later list adds to its quantity;
+// the wishlist keeps one entry per product and variant.
+func saveItem(q queryer, userID interface{}, list string, productID int, variantID *int, quantity int) (int, error) {
+	var itemID int
+	err := q.QueryRow(`
+		SELECT id FROM saved_items
+		WHERE user_id = ? AND list = ? AND product_id = ? AND variant_id <=> ?`,
+		userID, list, productID, variantID).Scan(&itemID)
+	if err == nil {
+		if list == models.ListSaveForLater {
+			_, err = q.Exec("UPDATE saved_items SET quantity = quantity + ? WHERE id = ?", quantity, itemID)
+		}
+		return itemID, err
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := q.Exec(`
+		INSERT INTO saved_items (user_id, list, product_id, variant_id, quantity, price_when_added)
+		SELECT ?, ?, p.id, ?, ?, COALESCE(v.price, p.price)
+		FROM products p
+		LEFT JOIN product_variants v ON v.id = ?
+		WHERE p.id = ?`,
+		userID, list, variantID, quantity, variantID, productID)
+	if err != nil {
+		return 0, err
+	}
+
+	newItemID, err := result.LastInsertId()
+	return int(newItemID), err
+}
+
+// GetSavedItems lists the user's wishlist or save-for-later items, flagging price drops
+func GetSavedItems(c *gin.Context) {
+	list, ok := savedList(c)
+	if !ok {
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	// Show prices in the requested currency
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	rows, err := config.DB.Query(`
+		SELECT si.id, si.product_id, si.variant_id, `+variantTitle+`, si.quantity, si.price_when_added, si.created_at,
+		       p.name, p.description, p.price, pp.price, v.price,
+		       IF(p.status = 'published', COALESCE(v.stock, p.stock), 0)
+		FROM saved_items si
+		JOIN products p ON p.id = si.product_id
+		LEFT JOIN product_variants v ON v.id = si.variant_id
+		`+productPriceJoin+`
+		WHERE si.user_id = ? AND si.list = ?
+		ORDER BY si.created_at DESC`,
+		currency.Code, userID, list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch saved items"})
+		return
+	}
+	defer rows.Close()
+
+	items := []models.SavedItem{}
+	for rows.Next() {
+		var item models.SavedItem
+		var variantID sql.NullInt64
+		var override, variantOverride *models.Money
+		var stock int
+
+		err := rows.Scan(
+			&item.ID,
+			&item.ProductID,
+			&variantID,
+			&item.VariantTitle,
+			&item.Quantity,
+			&item.PriceWhenAdded,
+			&item.CreatedAt,
+			&item.Product.Name,
+			&item.Product.Description,
+			&item.Product.Price,
+			&override,
+			&variantOverride,
+			&stock,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process saved items"})
+			return
+		}
+
+		item.List = list
+		item.VariantID = nullIntPtr(variantID)
+		item.InStock = stock > 0
+		item.Product.ID = item.ProductID
+		item.Product.Stock = stock
+
+		// Compare base prices, then show everything in the requested currency
+		basePrice := item.Product.Price
+		if variantOverride != nil {
+			basePrice = *variantOverride
+		}
+		if basePrice < item.PriceWhenAdded {
+			item.PriceDropped = true
+			item.PriceDrop = currency.FromBase(item.PriceWhenAdded - basePrice)
+		}
+		item.PriceWhenAdded = currency.FromBase(item.PriceWhenAdded)
+		item.Product.Price = variantPrice(item.Product.Price, override, variantOverride, currency)
+		item.Product.Currency = currency.Code
+
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"list": list, "items": items})
+}
+
+// AddSavedItem adds a product to the user's wishlist or save-for-later list
+func AddSavedItem(c *gin.Context) {
+	list, ok := savedList(c)
+	if !ok {
+		return
+	}
+
+	var input models.SavedItemInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+	if input.Quantity < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be at least 1"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	// Check if product exists and is still sold
+	var productExists bool
+	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ? AND status = 'published')", input.ProductID).Scan(&productExists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !productExists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	// Check the variant belongs to the product
+	if input.VariantID != nil {
+		var variantExists bool
+		err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_variants WHERE id = ? AND product_id = ?)",
+			*input.VariantID, input.ProductID).Scan(&variantExists)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		if !variantExists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "variant not found for this product"})
+			return
+		}
+	}
+
+	itemID, err := saveItem(config.DB, userID, list, input.ProductID, input.VariantID, input.Quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "item saved successfully",
+		"item_id": itemID,
+	})
+}
+
+// RemoveSavedItem removes an item from the user's wishlist or save-for-later list
+func RemoveSavedItem(c *gin.Context) {
+	list, ok := savedList(c)
+	if !ok {
+		return
+	}
+
+	// Get item ID from URL
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM saved_items WHERE id = ? AND user_id = ? AND list = ?", itemID, userID, list)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove saved item"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saved item not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "saved item removed successfully"})
+}
+
+// MoveSavedItemToCart moves a saved item into the cart, with the same stock checks as AddToCart
+func MoveSavedItemToCart(c *gin.Context) {
+	list, ok := savedList(c)
+	if !ok {
+		return
+	}
+
+	// Get item ID from URL
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var productID, quantity int
+	var variantID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT product_id, variant_id, quantity FROM saved_items
+		WHERE id = ? AND user_id = ? AND list = ? FOR UPDATE`,
+		itemID, userID, list).Scan(&productID, &variantID, &quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "saved item not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	if _, err := addCartItem(tx, c, productID, int(variantID.Int64), quantity); err != nil {
+		writeCartItemError(c, err, "failed to add item to cart")
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM saved_items WHERE id = ?", itemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove saved item"})
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "item moved to cart successfully"})
+}
+
+// MoveCartItemToList takes an item out of the cart and puts it on the wishlist or save-for-later list
+func MoveCartItemToList(c *gin.Context) {
+	list, ok := savedList(c)
+	if !ok {
+		return
+	}
+
+	// Get item ID from URL
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user ID not found"})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Verify the item is in the user's cart
+	var productID, quantity int
+	var variantID sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT ci.product_id, ci.variant_id, ci.quantity FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		WHERE ci.id = ? AND c.user_id = ? FOR UPDATE`,
+		itemID, userID).Scan(&productID, &variantID, &quantity)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found or not authorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	if _, err := saveItem(tx, userID, list, productID, nullIntPtr(variantID), quantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save item"})
+		return
+	}
+
+	// Removing the cart item also releases its stock hold
+	_, err = tx.Exec("DELETE FROM cart_items WHERE id = ?", itemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove item from cart"})
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "item moved to " + list + " successfully"})
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goapi/config"
+	"goapi/models"
+	"goapi/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idPlaceholders returns "?, ?, ..." and the arguments for an IN list of IDs
+// or other keys such as SKUs
+func idPlaceholders[T int | string](ids []T) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+// indexProducts loads products into the search index, or every product when
+// no IDs are given. Listed products that no longer exist or aren't published
+// are removed.
+func indexProducts(q queryer, productIDs ...int) error {
+	query := `SELECT id, name, description, price FROM products WHERE status = 'published'`
+	var args []interface{}
+	if len(productIDs) > 0 {
+		var placeholders string
+		placeholders, args = idPlaceholders(productIDs)
+		query += ` AND id IN (` + placeholders + `)`
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	var docs []utils.SearchDocument
+	for rows.Next() {
+		var doc utils.SearchDocument
+		if err := rows.Scan(&doc.ID, &doc.Name, &doc.Description, &doc.Price); err != nil {
+			rows.Close()
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	found := make(map[int]bool, len(docs))
+	ids := make([]int, len(docs))
+	for i, doc := range docs {
+		found[doc.ID] = true
+		ids[i] = doc.ID
+	}
+
+	_, variants, err := loadProductVariants(q, ids...)
+	if err != nil {
+		return err
+	}
+
+	productCategories, err := loadProductCategories(q, ids...)
+	if err != nil {
+		return err
+	}
+
+	categories, err := loadCategories(q)
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		// Option values are facets, keyed by lowercased option name
+		doc.Options = make(map[string][]string)
+		seenValues := make(map[string]bool)
+		for _, variant := range variants[doc.ID] {
+			for name, value := range variant.Options {
+				key := strings.ToLower(name)
+				if !seenValues[key+"\x00"+value] {
+					seenValues[key+"\x00"+value] = true
+					doc.Options[key] = append(doc.Options[key], value)
+				}
+			}
+		}
+
+		// A product in a subcategory is also found under its parents
+		seen := make(map[string]bool)
+		for _, category := range productCategories[doc.ID] {
+			for _, ancestor := range categoryPath(categories, category.ID) {
+				if !seen[ancestor.Slug] {
+					seen[ancestor.Slug] = true
+					doc.Categories = append(doc.Categories, ancestor.Slug)
+				}
+			}
+		}
+		if err := utils.Search.Index(doc); err != nil {
+			return err
+		}
+	}
+
+	for _, productID := range productIDs {
+		if !found[productID] {
+			if err := utils.Search.Remove(productID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncSearchIndex refreshes a product in the search index after it changes.
+// Failures are logged rather than failing the request; the index catches up
+// on the next change or restart.
+func syncSearchIndex(productID int) {
+	if err := indexProducts(config.DB, productID); err != nil {
+		log.Printf("failed to update search index for product %d: %v", productID, err)
+	}
+}
+
+// syncSearchIndexAll reindexes the whole catalog after a change that touches
+// many products, such as renaming or moving a category
+func syncSearchIndexAll() {
+	if err := indexProducts(config.DB); err != nil {
+		log.Println("failed to rebuild search index:", err)
+	}
+}
+
+// RebuildSearchIndex indexes the whole catalog, for use at startup
+func RebuildSearchIndex() error {
+	return indexProducts(config.DB)
+}
+
+// loadProductsByID reads products priced in a currency, with their variants,
+// categories and images, keyed by ID. Only published products are read.
+func loadProductsByID(q queryer, currency models.Currency, productIDs []int) (map[int]models.Product, error) {
+	products := make(map[int]models.Product, len(productIDs))
+	if len(productIDs) == 0 {
+		return products, nil
+	}
+
+	placeholders, args := idPlaceholders(productIDs)
+	rows, err := q.Query(`
+		SELECT p.id, p.name, p.description, p.price, pp.price, p.stock, p.weight, p.tax_class_id, p.created_by, p.created_at, p.updated_at
+		FROM products p `+productPriceJoin+`
+		WHERE p.id IN (`+placeholders+`) AND p.status = 'published'`,
+		append([]interface{}{currency.Code}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		var override *models.Money
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Description,
+			&product.Price,
+			&override,
+			&product.Stock,
+			&product.Weight,
+			&product.TaxClassID,
+			&product.CreatedBy,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		product.Price = localPrice(product.Price, override, currency)
+		product.Currency = currency.Code
+		products[product.ID] = product
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	options, variants, err := loadProductVariants(q, productIDs...)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := loadProductCategories(q, productIDs...)
+	if err != nil {
+		return nil, err
+	}
+	images, err := loadProductImages(q, productIDs...)
+	if err != nil {
+		return nil, err
+	}
+	for id, product := range products {
+		product.Options = options[id]
+		product.Variants = variants[id]
+		localVariantPrices(product.Variants, currency)
+		product.Categories = categories[id]
+		product.Images = images[id]
+		products[id] = product
+	}
+
+	return products, nil
+}
+
+// splitList splits a comma separated query parameter, dropping blanks
+func splitList(value string) []string {
+	var values []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			values = append(values, field)
+		}
+	}
+	return values
+}
+
+// SearchProducts finds products matching the q parameter, best match first.
+// Results can be narrowed with options (name:value pairs), category (comma
+// separated) and price_bucket, and come with facet counts for each.
+func SearchProducts(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+
+	// Show prices in the requested currency
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	// Pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	options, err := parseOptionFilters(c.Query("options"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := utils.Search.Search(utils.SearchQuery{
+		Text:        text,
+		Options:     options,
+		Categories:  splitList(c.Query("category")),
+		PriceBucket: c.Query("price_bucket"),
+		Offset:      (page - 1) * limit,
+		Limit:       limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search products"})
+		return
+	}
+
+	productIDs := make([]int, len(result.Hits))
+	for i, hit := range result.Hits {
+		productIDs[i] = hit.ID
+	}
+
+	found, err := loadProductsByID(config.DB, currency, productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch products"})
+		return
+	}
+
+	// Keep the relevance order; skip anything deleted since it was indexed
+	products := []models.Product{}
+	for _, productID := range productIDs {
+		if product, ok := found[productID]; ok {
+			products = append(products, product)
+		}
+	}
+
+	// Show price bucket bounds in the requested currency
+	priceBuckets := []gin.H{}
+	for _, facet := range result.Facets.PriceBuckets {
+		for _, bucket := range utils.PriceBuckets {
+			if bucket.Key != facet.Value {
+				continue
+			}
+			entry := gin.H{"value": facet.Value, "count": facet.Count, "min": currency.FromBase(bucket.Min)}
+			if bucket.Max != 0 {
+				entry["max"] = currency.FromBase(bucket.Max)
+			}
+			priceBuckets = append(priceBuckets, entry)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":    text,
+		"products": products,
+		"facets": gin.H{
+			"options":       result.Facets.Options,
+			"categories":    result.Facets.Categories,
+			"price_buckets": priceBuckets,
+		},
+		"pagination": gin.H{
+			"total":       result.Total,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (result.Total + limit - 1) / limit,
+		},
+	})
+}
+
+// SuggestProducts returns product names completing partly typed text, for autocomplete
+func SuggestProducts(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusOK, gin.H{"suggestions": []string{}})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if limit < 1 || limit > 20 {
+		limit = 5
+	}
+
+	suggestions, err := utils.Search.Suggest(text, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suggest products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
@@ -0,0 +1,590 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const shippingMethodColumns = `id, code, name, description, rate_type, free_shipping_threshold,
+	is_active, display_order, created_at, updated_at`
+
+// scanShippingMethod reads a row selected with shippingMethodColumns
+func scanShippingMethod(row scanner) (models.ShippingMethod, error) {
+	var method models.ShippingMethod
+
+	err := row.Scan(
+		&method.ID,
+		&method.Code,
+		&method.Name,
+		&method.Description,
+		&method.RateType,
+		&method.FreeShippingThreshold,
+		&method.IsActive,
+		&method.DisplayOrder,
+		&method.CreatedAt,
+		&method.UpdatedAt,
+	)
+	if err != nil {
+		return method, err
+	}
+
+	return method, nil
+}
+
+// matchingZones returns the IDs of zones covering an address, most specific first
+func matchingZones(q queryer, address models.ShippingAddress) ([]int, error) {
+	rows, err := q.Query(`
+		SELECT id FROM shipping_zones
+		WHERE country = ?
+		  AND (state IS NULL OR state = ?)
+		  AND (postal_code_prefix IS NULL OR ? LIKE CONCAT(postal_code_prefix, '%'))
+		ORDER BY LENGTH(COALESCE(postal_code_prefix, '')) DESC, state IS NULL, id`,
+		strings.TrimSpace(address.Country), strings.TrimSpace(address.State), strings.TrimSpace(address.PostalCode))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zoneIDs []int
+	for rows.Next() {
+		var zoneID int
+		if err := rows.Scan(&zoneID); err != nil {
+			return nil, err
+		}
+		zoneIDs = append(zoneIDs, zoneID)
+	}
+	return zoneIDs, rows.Err()
+}
+
+// findShippingRate picks the rate for a method, trying the most specific zone
+// first and falling back to rates that apply to every zone
+func findShippingRate(q queryer, method models.ShippingMethod, zoneIDs []int, weight float64) (models.Money, bool, error) {
+	query := `
+		SELECT rate FROM shipping_rates
+		WHERE method_id = ? AND zone_id <=> ?`
+	if method.RateType == models.ShippingRateWeight {
+		query += ` AND min_weight <= ? AND (max_weight IS NULL OR max_weight > ?)`
+	}
+	query += ` ORDER BY min_weight LIMIT 1`
+
+	candidates := make([]interface{}, 0, len(zoneIDs)+1)
+	for _, zoneID := range zoneIDs {
+		candidates = append(candidates, zoneID)
+	}
+	candidates = append(candidates, nil)
+
+	for _, zoneID := range candidates {
+		args := []interface{}{method.ID, zoneID}
+		if method.RateType == models.ShippingRateWeight {
+			args = append(args, weight, weight)
+		}
+
+		var rate models.Money
+		err := q.QueryRow(query, args...).Scan(&rate)
+		if err == nil {
+			return rate, true, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, false, err
+		}
+	}
+
+	return 0, false, nil
+}
+
+// applyShipping prices the chosen shipping method for the quote's address and weight
+func applyShipping(q queryer, quote *models.CheckoutQuote, address models.ShippingAddress, methodID *int) error {
+	quote.TotalWeight = 0
+	for _, line := range quote.Lines {
+		quote.TotalWeight += line.Weight * float64(line.Quantity)
+	}
+
+	if methodID == nil {
+		// Shipping is only optional while no methods have been configured
+		var activeMethods int
+		err := q.QueryRow("SELECT COUNT(*) FROM shipping_methods WHERE is_active = TRUE").Scan(&activeMethods)
+		if err != nil {
+			return err
+		}
+		if activeMethods > 0 {
+			return &checkoutError{"shipping method is required"}
+		}
+		return nil
+	}
+
+	method, err := scanShippingMethod(q.QueryRow(
+		`SELECT `+shippingMethodColumns+` FROM shipping_methods WHERE id = ? AND is_active = TRUE`, *methodID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &checkoutError{"shipping method not found"}
+		}
+		return err
+	}
+
+	zoneIDs, err := matchingZones(q, address)
+	if err != nil {
+		return err
+	}
+
+	rate, ok, err := findShippingRate(q, method, zoneIDs, quote.TotalWeight)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &checkoutError{fmt.Sprintf("%s is not available for this address", method.Name)}
+	}
+
+	// Rates and thresholds are set in the base currency
+	rate = quote.FromBase(rate)
+	if method.FreeShippingThreshold != nil && quote.Subtotal >= quote.FromBase(*method.FreeShippingThreshold) {
+		rate = 0
+	}
+
+	quote.ShippingMethodID = &method.ID
+	quote.ShippingMethod = method.Name
+	quote.Shipping = rate
+	return nil
+}
+
+// validateShippingMethodInput checks a shipping method before it is saved
+func validateShippingMethodInput(input models.ShippingMethodInput) string {
+	if input.RateType != models.ShippingRateFlat && input.RateType != models.ShippingRateWeight {
+		return "rate_type must be flat or weight"
+	}
+	if input.FreeShippingThreshold != nil && *input.FreeShippingThreshold < 0 {
+		return "free_shipping_threshold cannot be negative"
+	}
+	return ""
+}
+
+// GetShippingMethods lists the active shipping methods with their rates
+func GetShippingMethods(c *gin.Context) {
+	listShippingMethods(c, true)
+}
+
+// GetAllShippingMethods lists every shipping method with its rates (admin only)
+func GetAllShippingMethods(c *gin.Context) {
+	listShippingMethods(c, false)
+}
+
+func listShippingMethods(c *gin.Context, activeOnly bool) {
+	query := `SELECT ` + shippingMethodColumns + ` FROM shipping_methods`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY display_order, id`
+
+	rows, err := config.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shipping methods"})
+		return
+	}
+	defer rows.Close()
+
+	methods := []models.ShippingMethod{}
+	methodIndex := map[int]int{}
+
+	for rows.Next() {
+		method, err := scanShippingMethod(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process shipping methods"})
+			return
+		}
+		methodIndex[method.ID] = len(methods)
+		methods = append(methods, method)
+	}
+	rows.Close()
+
+	// Attach rates
+	rateRows, err := config.DB.Query(`
+		SELECT id, method_id, zone_id, min_weight, max_weight, rate
+		FROM shipping_rates
+		ORDER BY method_id, zone_id, min_weight`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shipping rates"})
+		return
+	}
+	defer rateRows.Close()
+
+	for rateRows.Next() {
+		var rate models.ShippingRate
+		var zoneID sql.NullInt64
+		var maxWeight sql.NullFloat64
+
+		err := rateRows.Scan(&rate.ID, &rate.MethodID, &zoneID, &rate.MinWeight, &maxWeight, &rate.Rate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process shipping rates"})
+			return
+		}
+
+		idx, ok := methodIndex[rate.MethodID]
+		if !ok {
+			continue
+		}
+
+		rate.ZoneID = nullIntPtr(zoneID)
+		if maxWeight.Valid {
+			rate.MaxWeight = &maxWeight.Float64
+		}
+		methods[idx].Rates = append(methods[idx].Rates, rate)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipping_methods": methods})
+}
+
+// CreateShippingMethod adds a new shipping method (admin only)
+func CreateShippingMethod(c *gin.Context) {
+	var input models.ShippingMethodInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateShippingMethodInput(input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO shipping_methods (code, name, description, rate_type, free_shipping_threshold, is_active, display_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		input.Code, input.Name, input.Description, input.RateType, input.FreeShippingThreshold, isActive, input.DisplayOrder)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shipping method code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create shipping method"})
+		return
+	}
+
+	// Get shipping method ID
+	methodID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get shipping method ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":            "shipping method created successfully",
+		"shipping_method_id": methodID,
+	})
+}
+
+// UpdateShippingMethod updates an existing shipping method (admin only)
+func UpdateShippingMethod(c *gin.Context) {
+	// Get shipping method ID from URL
+	methodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shipping method ID"})
+		return
+	}
+
+	var input models.ShippingMethodInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateShippingMethodInput(input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Check if shipping method exists
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM shipping_methods WHERE id = ?)", methodID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shipping method not found"})
+		return
+	}
+
+	isActive := true
+	if input.IsActive != nil {
+		isActive = *input.IsActive
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE shipping_methods
+		SET code = ?, name = ?, description = ?, rate_type = ?, free_shipping_threshold = ?, is_active = ?, display_order = ?
+		WHERE id = ?`,
+		input.Code, input.Name, input.Description, input.RateType, input.FreeShippingThreshold, isActive, input.DisplayOrder, methodID)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shipping method code already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shipping method"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shipping method updated successfully"})
+}
+
+// DeleteShippingMethod removes a shipping method and its rates (admin only)
+func DeleteShippingMethod(c *gin.Context) {
+	// Get shipping method ID from URL
+	methodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shipping method ID"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM shipping_methods WHERE id = ?", methodID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shipping method"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shipping method not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shipping method deleted successfully"})
+}
+
+// UpdateShippingRates replaces the rate table of a shipping method (admin only)
+func UpdateShippingRates(c *gin.Context) {
+	// Get shipping method ID from URL
+	methodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shipping method ID"})
+		return
+	}
+
+	var input []models.ShippingRateInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, rate := range input {
+		if rate.Rate < 0 || rate.MinWeight < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rate and min_weight cannot be negative"})
+			return
+		}
+		if rate.MaxWeight != nil && *rate.MaxWeight <= rate.MinWeight {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_weight must be greater than min_weight"})
+			return
+		}
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Check if shipping method exists
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM shipping_methods WHERE id = ?)", methodID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shipping method not found"})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM shipping_rates WHERE method_id = ?", methodID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shipping rates"})
+		return
+	}
+
+	for _, rate := range input {
+		_, err = tx.Exec(`
+			INSERT INTO shipping_rates (method_id, zone_id, min_weight, max_weight, rate)
+			VALUES (?, ?, ?, ?, ?)`,
+			methodID, rate.ZoneID, rate.MinWeight, rate.MaxWeight, rate.Rate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shipping rates"})
+			return
+		}
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shipping rates updated successfully"})
+}
+
+// GetShippingZones lists all shipping zones (admin only)
+func GetShippingZones(c *gin.Context) {
+	rows, err := config.DB.Query(`
+		SELECT id, name, country, state, postal_code_prefix, created_at, updated_at
+		FROM shipping_zones
+		ORDER BY country, state, postal_code_prefix`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shipping zones"})
+		return
+	}
+	defer rows.Close()
+
+	zones := []models.ShippingZone{}
+
+	for rows.Next() {
+		var zone models.ShippingZone
+		var state, postalCodePrefix sql.NullString
+
+		err := rows.Scan(&zone.ID, &zone.Name, &zone.Country, &state, &postalCodePrefix, &zone.CreatedAt, &zone.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process shipping zones"})
+			return
+		}
+
+		zone.State = state.String
+		zone.PostalCodePrefix = postalCodePrefix.String
+		zones = append(zones, zone)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipping_zones": zones})
+}
+
+// nullIfEmpty stores empty optional strings as NULL
+func nullIfEmpty(s string) interface{} {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return strings.TrimSpace(s)
+}
+
+// CreateShippingZone adds a new shipping zone (admin only)
+func CreateShippingZone(c *gin.Context) {
+	var input models.ShippingZoneInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := config.DB.Exec(`
+		INSERT INTO shipping_zones (name, country, state, postal_code_prefix) VALUES (?, ?, ?, ?)`,
+		input.Name, strings.TrimSpace(input.Country), nullIfEmpty(input.State), nullIfEmpty(input.PostalCodePrefix))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create shipping zone"})
+		return
+	}
+
+	// Get shipping zone ID
+	zoneID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get shipping zone ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":          "shipping zone created successfully",
+		"shipping_zone_id": zoneID,
+	})
+}
+
+// UpdateShippingZone updates an existing shipping zone (admin only)
+func UpdateShippingZone(c *gin.Context) {
+	// Get shipping zone ID from URL
+	zoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shipping zone ID"})
+		return
+	}
+
+	var input models.ShippingZoneInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if shipping zone exists
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM shipping_zones WHERE id = ?)", zoneID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shipping zone not found"})
+		return
+	}
+
+	_, err = config.DB.Exec(`
+		UPDATE shipping_zones SET name = ?, country = ?, state = ?, postal_code_prefix = ? WHERE id = ?`,
+		input.Name, strings.TrimSpace(input.Country), nullIfEmpty(input.State), nullIfEmpty(input.PostalCodePrefix), zoneID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shipping zone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shipping zone updated successfully"})
+}
+
+// DeleteShippingZone removes a shipping zone and the rates defined for it (admin only)
+func DeleteShippingZone(c *gin.Context) {
+	// Get shipping zone ID from URL
+	zoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shipping zone ID"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM shipping_zones WHERE id = ?", zoneID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shipping zone"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shipping zone not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shipping zone deleted successfully"})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"goapi/config"
+	"goapi/models"
+	"goapi/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taxColumns selects a product's tax class and rate; products without a
+// class fall back to the configured default rate. Pass utils.Tax.DefaultRate
+// as the query argument and LEFT JOIN tax_classes tc ON tc.id = p.tax_class_id.
+const taxColumns = `COALESCE(tc.name, 'standard'), COALESCE(tc.rate, ?)`
+
+// applyTax works out per-line and per-rate tax for a quote. Order-level
+// discounts are spread over the lines in proportion to their totals so the
+// tax is charged on what the customer actually pays. Shipping is taxed at
+// the default rate.
+func applyTax(quote *models.CheckoutQuote) {
+	quote.PricesIncludeTax = utils.Tax.PricesIncludeTax
+	quote.Tax = 0
+	quote.TaxBreakdown = []models.TaxBreakdown{}
+
+	breakdownIndex := map[string]int{}
+	addToBreakdown := func(taxClass string, rate float64, taxable, tax models.Money) {
+		key := taxClass + "@" + strconv.FormatFloat(rate, 'f', 2, 64)
+		idx, ok := breakdownIndex[key]
+		if !ok {
+			idx = len(quote.TaxBreakdown)
+			breakdownIndex[key] = idx
+			quote.TaxBreakdown = append(quote.TaxBreakdown, models.TaxBreakdown{
+				TaxClass: taxClass,
+				Rate:     rate,
+			})
+		}
+		quote.TaxBreakdown[idx].TaxableAmount += taxable
+		quote.TaxBreakdown[idx].TaxAmount += tax
+	}
+
+	itemDiscount := quote.Discount - quote.ShippingDiscount
+	remainingDiscount := itemDiscount
+
+	for i := range quote.Lines {
+		line := &quote.Lines[i]
+
+		taxable := line.LineTotal
+		if itemDiscount > 0 && quote.Subtotal > 0 {
+			share := itemDiscount.Share(line.LineTotal, quote.Subtotal)
+			if i == len(quote.Lines)-1 {
+				// The last line absorbs any rounding difference
+				share = remainingDiscount
+			}
+			remainingDiscount -= share
+			taxable -= share
+		}
+
+		line.TaxAmount, _ = utils.Tax.Split(taxable, line.TaxRate)
+		quote.Tax += line.TaxAmount
+		addToBreakdown(line.TaxClass, line.TaxRate, taxable, line.TaxAmount)
+	}
+
+	if shipping := quote.Shipping - quote.ShippingDiscount; shipping > 0 {
+		shippingTax, _ := utils.Tax.Split(shipping, utils.Tax.DefaultRate)
+		quote.Tax += shippingTax
+		addToBreakdown("shipping", utils.Tax.DefaultRate, shipping, shippingTax)
+	}
+}
+
+// GetAllTaxClasses retrieves all tax classes (admin only)
+func GetAllTaxClasses(c *gin.Context) {
+	taxClasses := []models.TaxClass{}
+
+	rows, err := config.DB.Query(`SELECT id, name, rate, created_at, updated_at FROM tax_classes ORDER BY name`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tax classes"})
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taxClass models.TaxClass
+		err := rows.Scan(&taxClass.ID, &taxClass.Name, &taxClass.Rate, &taxClass.CreatedAt, &taxClass.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process tax classes"})
+			return
+		}
+		taxClasses = append(taxClasses, taxClass)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tax_classes":        taxClasses,
+		"default_rate":       utils.Tax.DefaultRate,
+		"prices_include_tax": utils.Tax.PricesIncludeTax,
+	})
+}
+
+// CreateTaxClass adds a new tax class (admin only)
+func CreateTaxClass(c *gin.Context) {
+	var input models.TaxClassInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if *input.Rate < 0 || *input.Rate > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be between 0 and 100"})
+		return
+	}
+
+	result, err := config.DB.Exec("INSERT INTO tax_classes (name, rate) VALUES (?, ?)", input.Name, *input.Rate)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tax class already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tax class"})
+		return
+	}
+
+	// Get tax class ID
+	taxClassID, err := result.LastInsertId()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get tax class ID"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "tax class created successfully",
+		"tax_class_id": taxClassID,
+	})
+}
+
+// UpdateTaxClass updates an existing tax class (admin only). Past orders keep the rate they were charged.
+func UpdateTaxClass(c *gin.Context) {
+	// Get tax class ID from URL
+	taxClassID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tax class ID"})
+		return
+	}
+
+	var input models.TaxClassInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if *input.Rate < 0 || *input.Rate > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be between 0 and 100"})
+		return
+	}
+
+	// Check if tax class exists
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tax_classes WHERE id = ?)", taxClassID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tax class not found"})
+		return
+	}
+
+	_, err = config.DB.Exec("UPDATE tax_classes SET name = ?, rate = ? WHERE id = ?", input.Name, *input.Rate, taxClassID)
+	if err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tax class already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update tax class"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tax class updated successfully"})
+}
+
+// DeleteTaxClass removes a tax class (admin only); its products fall back to the default rate
+func DeleteTaxClass(c *gin.Context) {
+	// Get tax class ID from URL
+	taxClassID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tax class ID"})
+		return
+	}
+
+	result, err := config.DB.Exec("DELETE FROM tax_classes WHERE id = ?", taxClassID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tax class"})
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tax class not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tax class deleted successfully"})
+}
@@ -0,0 +1,590 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goapi/config"
+	"goapi/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// variantTitle is a variant's option values in option order, e.g. "M / Red",
+// for use in a query over product_variants v
+const variantTitle = `COALESCE((
+		SELECT GROUP_CONCAT(ov.value ORDER BY o.position, o.id SEPARATOR ' / ')
+		FROM product_variant_values vv
+		JOIN product_option_values ov ON ov.id = vv.option_value_id
+		JOIN product_options o ON o.id = ov.option_id
+		WHERE vv.variant_id = v.id), '')`
+
+// variantPrice is the price of a cart or order line: the variant's own price
+// when it has one, otherwise the product's price in the currency
+func variantPrice(base models.Money, override, variant *models.Money, currency models.Currency) models.Money {
+	if variant != nil {
+		return currency.FromBase(*variant)
+	}
+	return localPrice(base, override, currency)
+}
+
+// loadProductVariants reads the options and variants of a set of products,
+// keyed by product ID and in display order
+func loadProductVariants(q queryer, productIDs ...int) (map[int][]models.ProductOption, map[int][]models.ProductVariant, error) {
+	options := make(map[int][]models.ProductOption)
+	variants := make(map[int][]models.ProductVariant)
+	if len(productIDs) == 0 {
+		return options, variants, nil
+	}
+
+	placeholders, args := idPlaceholders(productIDs)
+	rows, err := q.Query(`
+		SELECT o.product_id, o.id, o.name, o.position, ov.id, ov.value, ov.position
+		FROM product_options o
+		JOIN product_option_values ov ON ov.option_id = o.id
+		WHERE o.product_id IN (`+placeholders+`)
+		ORDER BY o.product_id, o.position, o.id, ov.position, ov.id`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Option names by option value, for labelling variants
+	optionNames := make(map[int]string)
+	for rows.Next() {
+		var productID int
+		var option models.ProductOption
+		var value models.ProductOptionValue
+		err := rows.Scan(&productID, &option.ID, &option.Name, &option.Position, &value.ID, &value.Value, &value.Position)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+
+		productOptions := options[productID]
+		if n := len(productOptions); n == 0 || productOptions[n-1].ID != option.ID {
+			productOptions = append(productOptions, option)
+		}
+		last := &productOptions[len(productOptions)-1]
+		last.Values = append(last.Values, value)
+		options[productID] = productOptions
+		optionNames[value.ID] = option.Name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT v.id, v.product_id, v.sku, v.barcode, v.price, v.stock, v.created_at, v.updated_at, `+variantTitle+`
+		FROM product_variants v
+		WHERE v.product_id IN (`+placeholders+`)
+		ORDER BY v.product_id, v.id`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[int]*models.ProductVariant)
+	var order []*models.ProductVariant
+	for rows.Next() {
+		variant := &models.ProductVariant{Options: map[string]string{}}
+		var barcode sql.NullString
+		err := rows.Scan(
+			&variant.ID,
+			&variant.ProductID,
+			&variant.SKU,
+			&barcode,
+			&variant.Price,
+			&variant.Stock,
+			&variant.CreatedAt,
+			&variant.UpdatedAt,
+			&variant.Title,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if barcode.Valid {
+			variant.Barcode = &barcode.String
+		}
+		byID[variant.ID] = variant
+		order = append(order, variant)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(order) > 0 {
+		rows, err = q.Query(`
+			SELECT vv.variant_id, vv.option_value_id, ov.value
+			FROM product_variant_values vv
+			JOIN product_variants v ON v.id = vv.variant_id
+			JOIN product_option_values ov ON ov.id = vv.option_value_id
+			WHERE v.product_id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var variantID, valueID int
+			var value string
+			if err := rows.Scan(&variantID, &valueID, &value); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			if variant, ok := byID[variantID]; ok {
+				variant.Options[optionNames[valueID]] = value
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, variant := range order {
+		variants[variant.ProductID] = append(variants[variant.ProductID], *variant)
+	}
+	return options, variants, nil
+}
+
+// localVariantPrices shows variant price overrides in the request currency
+func localVariantPrices(variants []models.ProductVariant, currency models.Currency) {
+	for i := range variants {
+		if variants[i].Price != nil {
+			price := currency.FromBase(*variants[i].Price)
+			variants[i].Price = &price
+		}
+	}
+}
+
+// validateVariantsInput checks options have distinct names and values, and
+// that every variant picks one existing value of each option with no two
+// variants alike. Names and values are trimmed in place.
+func validateVariantsInput(input *models.ProductVariantsInput) string {
+	optionValues := make(map[string]map[string]bool)
+	for i := range input.Options {
+		option := &input.Options[i]
+		option.Name = strings.TrimSpace(option.Name)
+		key := strings.ToLower(option.Name)
+		if key == "" {
+			return "option names cannot be empty"
+		}
+		if optionValues[key] != nil {
+			return "option " + option.Name + " is listed more than once"
+		}
+
+		values := make(map[string]bool)
+		for j := range option.Values {
+			option.Values[j] = strings.TrimSpace(option.Values[j])
+			valueKey := strings.ToLower(option.Values[j])
+			if valueKey == "" {
+				return "option values cannot be empty"
+			}
+			if values[valueKey] {
+				return "value " + option.Values[j] + " is listed more than once for " + option.Name
+			}
+			values[valueKey] = true
+		}
+		optionValues[key] = values
+	}
+
+	if len(input.Options) == 0 && len(input.Variants) > 1 {
+		return "a product without options can only have one variant"
+	}
+
+	skus := make(map[string]bool)
+	barcodes := make(map[string]bool)
+	combinations := make(map[string]bool)
+	for i := range input.Variants {
+		variant := &input.Variants[i]
+		variant.SKU = strings.TrimSpace(variant.SKU)
+		if variant.SKU == "" {
+			return "sku cannot be empty"
+		}
+		if skus[variant.SKU] {
+			return "sku " + variant.SKU + " is used by more than one variant"
+		}
+		skus[variant.SKU] = true
+
+		if variant.Barcode != nil {
+			barcode := strings.TrimSpace(*variant.Barcode)
+			if barcode == "" {
+				variant.Barcode = nil
+			} else {
+				if barcodes[barcode] {
+					return "barcode " + barcode + " is used by more than one variant"
+				}
+				barcodes[barcode] = true
+				variant.Barcode = &barcode
+			}
+		}
+
+		if variant.Price != nil && *variant.Price <= 0 {
+			return "variant " + variant.SKU + " price must be greater than 0"
+		}
+		if variant.Stock < 0 {
+			return "variant " + variant.SKU + " stock cannot be negative"
+		}
+
+		if len(variant.Options) != len(input.Options) {
+			return "variant " + variant.SKU + " must have a value for every option"
+		}
+		picked := make([]string, len(input.Options))
+		for name, value := range variant.Options {
+			key := strings.ToLower(strings.TrimSpace(name))
+			values, ok := optionValues[key]
+			if !ok {
+				return "variant " + variant.SKU + " uses unknown option " + name
+			}
+			if !values[strings.ToLower(strings.TrimSpace(value))] {
+				return "variant " + variant.SKU + " uses unknown value " + value + " for " + name
+			}
+			for j, option := range input.Options {
+				if strings.ToLower(option.Name) == key {
+					picked[j] = strings.ToLower(strings.TrimSpace(value))
+				}
+			}
+		}
+
+		combination := strings.Join(picked, "\x00")
+		if combinations[combination] {
+			return "variant " + variant.SKU + " has the same options as another variant"
+		}
+		combinations[combination] = true
+	}
+
+	return ""
+}
+
+// saveProductVariants replaces a product's options and variants with a
+// validated input. Variants are matched to the existing ones by SKU; those
+// left out are deleted, taking them out of carts and wishlists.
+func saveProductVariants(tx *sql.Tx, productID int, input models.ProductVariantsInput) error {
+	// Options are rebuilt each time, which also clears the variants' values
+	if _, err := tx.Exec("DELETE FROM product_options WHERE product_id = ?", productID); err != nil {
+		return err
+	}
+
+	valueIDs := make(map[string]map[string]int)
+	for position, option := range input.Options {
+		result, err := tx.Exec("INSERT INTO product_options (product_id, name, position) VALUES (?, ?, ?)",
+			productID, option.Name, position)
+		if err != nil {
+			return err
+		}
+		optionID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		values := make(map[string]int)
+		for valuePosition, value := range option.Values {
+			result, err := tx.Exec("INSERT INTO product_option_values (option_id, value, position) VALUES (?, ?, ?)",
+				optionID, value, valuePosition)
+			if err != nil {
+				return err
+			}
+			valueID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			values[strings.ToLower(value)] = int(valueID)
+		}
+		valueIDs[strings.ToLower(option.Name)] = values
+	}
+
+	rows, err := tx.Query("SELECT id, sku FROM product_variants WHERE product_id = ?", productID)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]int)
+	for rows.Next() {
+		var variantID int
+		var sku string
+		if err := rows.Scan(&variantID, &sku); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[sku] = variantID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var kept []int
+	for _, variant := range input.Variants {
+		variantID, ok := existing[variant.SKU]
+		if ok {
+			_, err = tx.Exec("UPDATE product_variants SET barcode = ?, price = ?, stock = ? WHERE id = ?",
+				variant.Barcode, variant.Price, variant.Stock, variantID)
+			if err != nil {
+				return err
+			}
+		} else {
+			result, err := tx.Exec(`
+				INSERT INTO product_variants (product_id, sku, barcode, price, stock)
+				VALUES (?, ?, ?, ?, ?)`,
+				productID, variant.SKU, variant.Barcode, variant.Price, variant.Stock)
+			if err != nil {
+				return err
+			}
+			newVariantID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			variantID = int(newVariantID)
+		}
+		kept = append(kept, variantID)
+
+		for name, value := range variant.Options {
+			valueID := valueIDs[strings.ToLower(strings.TrimSpace(name))][strings.ToLower(strings.TrimSpace(value))]
+			_, err = tx.Exec("INSERT INTO product_variant_values (variant_id, option_value_id) VALUES (?, ?)", variantID, valueID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	removed := "product_id = ?"
+	args := []interface{}{productID}
+	if len(kept) > 0 {
+		placeholders, keptArgs := idPlaceholders(kept)
+		removed += " AND id NOT IN (" + placeholders + ")"
+		args = append(args, keptArgs...)
+	}
+
+	// Coupons for a removed variant are switched off rather than widened to
+	// the whole product when their variant_id is cleared
+	_, err = tx.Exec("UPDATE coupons SET is_active = FALSE WHERE variant_id IN (SELECT id FROM product_variants WHERE "+removed+")", args...)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM product_variants WHERE "+removed, args...); err != nil {
+		return err
+	}
+
+	return syncProductStock(tx, productID)
+}
+
+// syncProductStock sets a product's stock to the total of its variants.
+// Products without variants keep their own stock.
+func syncProductStock(q queryer, productID int) error {
+	_, err := q.Exec(`
+		UPDATE products
+		SET stock = COALESCE((SELECT SUM(stock) FROM product_variants WHERE product_id = ?), stock)
+		WHERE id = ?`, productID, productID)
+	return err
+}
+
+// errOutOfStock is returned by adjustStock when a deduction would take
+// stock below zero
+var errOutOfStock = errors.New("not enough stock")
+
+// adjustStock changes the stock of a product and, for variant lines, the
+// variant, so the product keeps the total of its variants. A deduction only
+// goes through if the stock covers it, and fails with errOutOfStock otherwise.
+func adjustStock(q queryer, productID int, variantID *int, delta int) error {
+	if variantID != nil {
+		if err := addStock(q, "product_variants", *variantID, delta); err != nil {
+			return err
+		}
+	}
+	return addStock(q, "products", productID, delta)
+}
+
+// addStock adds delta to the stock of one row of table, refusing to go below zero
+func addStock(q queryer, table string, id, delta int) error {
+	result, err := q.Exec("UPDATE "+table+" SET stock = stock + ? WHERE id = ? AND stock + ? >= 0", delta, id, delta)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 && delta < 0 {
+		return errOutOfStock
+	}
+	return nil
+}
+
+// parseOptionFilters reads a comma separated list of name:value pairs such as
+// "size:M,color:red" into values per lowercased option name
+func parseOptionFilters(value string) (map[string][]string, error) {
+	filters := make(map[string][]string)
+	for _, field := range splitList(value) {
+		name, optionValue, ok := strings.Cut(field, ":")
+		name, optionValue = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(optionValue)
+		if !ok || name == "" || optionValue == "" {
+			return nil, errors.New("options must be name:value pairs")
+		}
+		filters[name] = append(filters[name], optionValue)
+	}
+	return filters, nil
+}
+
+// GetProductVariants returns a published product's options and variants
+func GetProductVariants(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ? AND status = 'published')", productID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	// Show variant prices in the requested currency
+	currency, err := requestCurrency(config.DB, c)
+	if err != nil {
+		writeCheckoutError(c, err, "failed to load currency")
+		return
+	}
+
+	options, variants, err := loadProductVariants(config.DB, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product variants"})
+		return
+	}
+	localVariantPrices(variants[productID], currency)
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": productID,
+		"currency":   currency.Code,
+		"options":    options[productID],
+		"variants":   variants[productID],
+	})
+}
+
+// UpdateProductVariants replaces a product's options and variants (admin
+// only). Variants are matched by SKU; ones left out are removed.
+func UpdateProductVariants(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var input models.ProductVariantsInput
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if msg := validateVariantsInput(&input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Begin transaction
+	tx, err := config.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM products WHERE id = ? FOR UPDATE", productID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		}
+		return
+	}
+
+	if err := saveProductVariants(tx, productID, input); err != nil {
+		if config.IsDuplicateKey(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sku or barcode is already used by another product"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update product variants"})
+		return
+	}
+
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to commit transaction"})
+		return
+	}
+
+	syncSearchIndex(productID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "product variants updated successfully"})
+}
+
+// GetProductSizes lists a published product's sizes in the shape of the old
+// sizes endpoint, read from the variants of its Size option. It is kept,
+// read-only, for clients written before variants.
+func GetProductSizes(c *gin.Context) {
+	// Get product ID from URL
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var exists bool
+	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM products WHERE id = ? AND status = 'published')", productID).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+		return
+	}
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	options, variants, err := loadProductVariants(config.DB, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch product sizes"})
+		return
+	}
+
+	// Sizes are listed in the order of the Size option's values
+	sizes := []models.ProductSize{}
+	for _, option := range options[productID] {
+		if !strings.EqualFold(option.Name, "size") {
+			continue
+		}
+		for _, value := range option.Values {
+			for _, variant := range variants[productID] {
+				if variant.Options[option.Name] != value.Value {
+					continue
+				}
+				sizes = append(sizes, models.ProductSize{
+					ID:        variant.ID,
+					ProductID: productID,
+					SizeID:    value.ID,
+					SizeName:  value.Value,
+					Stock:     variant.Stock,
+					CreatedAt: variant.CreatedAt,
+					UpdatedAt: variant.UpdatedAt,
+				})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "sizes": sizes})
+}
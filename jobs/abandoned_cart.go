@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"goapi/config"
+	"goapi/utils"
+)
+
+// AbandonedCartConfig holds the abandoned cart job settings
+type AbandonedCartConfig struct {
+	IdleAfter  time.Duration // A cart with items untouched this long gets a reminder
+	PurgeAfter time.Duration // Empty carts untouched this long are deleted
+	BatchSize  int           // Reminders sent per run
+}
+
+// LoadAbandonedCartConfig reads ABANDONED_CART_HOURS (default 24) and
+// ABANDONED_CART_PURGE_DAYS (default 90)
+func LoadAbandonedCartConfig() AbandonedCartConfig {
+	cfg := AbandonedCartConfig{
+		IdleAfter:  24 * time.Hour,
+		PurgeAfter: 90 * 24 * time.Hour,
+		BatchSize:  100,
+	}
+
+	if hours, err := strconv.Atoi(os.Getenv("ABANDONED_CART_HOURS")); err == nil && hours > 0 {
+		cfg.IdleAfter = time.Duration(hours) * time.Hour
+	}
+	if days, err := strconv.Atoi(os.Getenv("ABANDONED_CART_PURGE_DAYS")); err == nil && days > 0 {
+		cfg.PurgeAfter = time.Duration(days) * 24 * time.Hour
+	}
+
+	return cfg
+}
+
+// StartAbandonedCartJob sends reminders for idle carts and purges old empty
+// carts every interval
+func StartAbandonedCartJob(interval time.Duration, cfg AbandonedCartConfig) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			remindAbandonedCarts(cfg)
+			purgeEmptyCarts(cfg)
+		}
+	}()
+}
+
+type abandonedCart struct {
+	cartID       int
+	email        string
+	username     string
+	itemCount    int
+	lastActivity time.Time
+}
+
+// remindAbandonedCarts notifies users whose carts have sat idle. A cart gets
+// one reminder per idle spell: touching the cart again makes it eligible anew.
+// Guest carts are skipped because there is no email to send to.
+func remindAbandonedCarts(cfg AbandonedCartConfig) {
+	rows, err := config.DB.Query(`
+		SELECT a.cart_id, a.email, a.username, a.item_count, a.last_activity FROM (
+			SELECT c.id AS cart_id, u.email, u.username, SUM(ci.quantity) AS item_count,
+			       GREATEST(c.updated_at, MAX(ci.updated_at)) AS last_activity
+			FROM carts c
+			JOIN users u ON u.id = c.user_id
+			JOIN cart_items ci ON ci.cart_id = c.id
+			GROUP BY c.id, c.updated_at, u.email, u.username
+		) a
+		WHERE a.last_activity < NOW() - INTERVAL ? SECOND
+		  AND NOT EXISTS (
+			SELECT 1 FROM cart_reminders cr WHERE cr.cart_id = a.cart_id AND cr.sent_at >= a.last_activity)
+		ORDER BY a.last_activity
+		LIMIT ?`, int(cfg.IdleAfter.Seconds()), cfg.BatchSize)
+	if err != nil {
+		log.Println("Failed to find abandoned carts:", err)
+		return
+	}
+
+	var carts []abandonedCart
+	for rows.Next() {
+		var cart abandonedCart
+		if err := rows.Scan(&cart.cartID, &cart.email, &cart.username, &cart.itemCount, &cart.lastActivity); err != nil {
+			log.Println("Failed to read abandoned cart:", err)
+			rows.Close()
+			return
+		}
+		carts = append(carts, cart)
+	}
+	rows.Close()
+
+	for _, cart := range carts {
+		notification := utils.Notification{
+			Kind:    "abandoned_cart",
+			To:      cart.email,
+			Subject: "You left something in your cart",
+			Body: fmt.Sprintf("Hi %s, you still have %d item(s) waiting in your cart. Come back and check out before they sell out.",
+				cart.username, cart.itemCount),
+			Data: map[string]interface{}{"cart_id": cart.cartID, "item_count": cart.itemCount},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := utils.Notifications.Send(ctx, notification)
+		cancel()
+		if err != nil {
+			// Not recorded, so the next run tries again
+			log.Printf("Failed to send abandoned cart reminder for cart %d: %v", cart.cartID, err)
+			continue
+		}
+
+		_, err = config.DB.Exec("INSERT INTO cart_reminders (cart_id, email, sent_at) VALUES (?, ?, NOW())",
+			cart.cartID, cart.email)
+		if err != nil {
+			log.Printf("Failed to record abandoned cart reminder for cart %d: %v", cart.cartID, err)
+		}
+	}
+}
+
+// purgeEmptyCarts deletes carts that have had no items for a long time
+func purgeEmptyCarts(cfg AbandonedCartConfig) {
+	result, err := config.DB.Exec(`
+		DELETE c FROM carts c
+		LEFT JOIN cart_items ci ON ci.cart_id = c.id
+		WHERE ci.id IS NULL AND c.updated_at < NOW() - INTERVAL ? SECOND`,
+		int(cfg.PurgeAfter.Seconds()))
+	if err != nil {
+		log.Println("Failed to purge empty carts:", err)
+		return
+	}
+
+	if purged, err := result.RowsAffected(); err == nil && purged > 0 {
+		log.Printf("Purged %d empty carts", purged)
+	}
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"goapi/handlers"
+)
+
+// LoadProductPurgeAfter reads ARCHIVED_PRODUCT_PURGE_DAYS (default 90), how
+// long a product stays archived before it may be purged
+func LoadProductPurgeAfter() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("ARCHIVED_PRODUCT_PURGE_DAYS")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return 90 * 24 * time.Hour
+}
+
+// StartProductPurgeJob deletes products that have been archived for longer
+// than purgeAfter and were never ordered, every interval
+func StartProductPurgeJob(interval, purgeAfter time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := handlers.PurgeArchivedProducts(purgeAfter)
+			if err != nil {
+				log.Println("Failed to purge archived products:", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d archived products", purged)
+			}
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"goapi/handlers"
+)
+
+// StartProductScheduler publishes and unpublishes products at their
+// scheduled times, checking every interval
+func StartProductScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			published, unpublished, err := handlers.ApplyProductSchedules()
+			if err != nil {
+				log.Println("Failed to apply product schedules:", err)
+				continue
+			}
+			if published > 0 || unpublished > 0 {
+				log.Printf("Published %d and unpublished %d scheduled products", published, unpublished)
+			}
+		}
+	}()
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"goapi/config"
+)
+
+// StartReservationReaper releases expired cart stock holds every interval.
+// Expired holds are already ignored when stock is checked; the reaper keeps
+// the table small and cart responses accurate.
+func StartReservationReaper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			reapExpiredReservations()
+		}
+	}()
+}
+
+func reapExpiredReservations() {
+	result, err := config.DB.Exec("DELETE FROM stock_reservations WHERE expires_at <= NOW()")
+	if err != nil {
+		log.Println("Failed to release expired stock reservations:", err)
+		return
+	}
+
+	if released, err := result.RowsAffected(); err == nil && released > 0 {
+		log.Printf("Released %d expired stock reservations", released)
+	}
+}
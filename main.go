@@ -2,11 +2,13 @@ package main
 
 import (
 	"log"
-	
+	"time"
 
 	"goapi/config" //change this to your module
 	"goapi/handlers" //change this to your module
+	"goapi/jobs" //change this to your module
 	"goapi/middleware" //change this to your module
+	"goapi/utils" //change this to your module
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +17,32 @@ func main() {
 	// Initialize database
 	config.InitDB()
 	defer config.DB.Close()
+
+	// Load the catalog into the search index
+	if err := handlers.RebuildSearchIndex(); err != nil {
+		log.Println("Failed to build search index:", err)
+	}
+
+	// Imports cut off by a restart won't finish, so mark them failed
+	if failed, err := handlers.FailInterruptedImports(); err != nil {
+		log.Println("Failed to mark interrupted product imports:", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted product imports as failed", failed)
+	}
+
+	// Release expired cart stock holds in the background
+	if utils.Reservations.Enabled() {
+		jobs.StartReservationReaper(time.Minute)
+	}
+
+	// Remind users about idle carts and purge old empty ones
+	jobs.StartAbandonedCartJob(15*time.Minute, jobs.LoadAbandonedCartConfig())
+
+	// Publish and unpublish products at their scheduled times
+	jobs.StartProductScheduler(time.Minute)
+
+	// Delete archived products that were never sold
+	jobs.StartProductPurgeJob(24*time.Hour, jobs.LoadProductPurgeAfter())
 	
 	// Create a new Gin router
 	r := gin.Default()
@@ -23,35 +51,79 @@ func main() {
 	
 	r.GET("/health-check", handlers.CheckConnection)
 
+	// Serve uploaded files when they are kept on local disk
+	if local, ok := utils.Files.(*utils.LocalStorage); ok {
+		r.Static(local.BaseURL, local.Dir)
+	}
+
 
 	
 	// Public routes (no authentication required)
 	r.POST("/register", handlers.RegisterUser)
 	r.POST("/login", handlers.LoginUser)
 	r.GET("/products", handlers.GetAllProducts)
+	r.GET("/products/search", handlers.SearchProducts)
+	r.GET("/products/search/suggest", handlers.SuggestProducts)
 	r.GET("/products/:id", handlers.GetProduct)
 
-	// Sizes routes
-	r.GET("/sizes", handlers.GetAllSizes)
-	r.GET("/products/:id/sizes", handlers.GetProductSizes)
+	// Category tree and curated collections
+	r.GET("/categories", handlers.GetCategories)
+	r.GET("/categories/:slug", handlers.GetCategory)
+	r.GET("/collections", handlers.GetCollections)
+	r.GET("/collections/:slug", handlers.GetCollection)
+
+	// Product options and variants
+	r.GET("/products/:id/variants", handlers.GetProductVariants)
+	r.GET("/products/:id/sizes", handlers.GetProductSizes) // Read-only alias kept for older clients
+
+	// Cart routes work for guests (X-Cart-Token) and signed-in users
+	cart := r.Group("/cart")
+	cart.Use(middleware.OptionalAuthMiddleware())
+	{
+		cart.GET("", handlers.GetCart)
+		cart.POST("/items", handlers.AddToCart)
+		cart.PUT("/items/:id", handlers.UpdateCartItem)
+		cart.DELETE("/items/:id", handlers.RemoveFromCart)
+		cart.DELETE("", handlers.ClearCart)
+		cart.PATCH("", handlers.PatchCart)
+		cart.POST("/coupon", handlers.ApplyCoupon)
+		cart.DELETE("/coupon", handlers.RemoveCoupon)
+	}
+
+	// Checkout works for guests too; they give an email and an inline address
+	checkout := r.Group("/checkout")
+	checkout.Use(middleware.OptionalAuthMiddleware())
+	{
+		checkout.POST("", handlers.Checkout)
+		checkout.POST("/quote", handlers.CheckoutQuote)
+	}
+
+	// Guest order lookup by order number and email, or by signed link
+	r.POST("/orders/lookup", handlers.LookupGuestOrder)
+	r.GET("/orders/lookup/:token", handlers.GetOrderByLink)
+
+	// Shipping methods available at checkout
+	r.GET("/shipping-methods", handlers.GetShippingMethods)
+
+	// Currencies customers can pick with ?currency= or X-Currency
+	r.GET("/currencies", handlers.GetCurrencies)
 
 	// Protected routes (authentication required)
 	auth := r.Group("/")
 	auth.Use(middleware.AuthMiddleware())
 	{
-		// Cart routes
-		auth.GET("/cart", handlers.GetCart)
-		auth.POST("/cart/items", handlers.AddToCart)
-		auth.PUT("/cart/items/:id", handlers.UpdateCartItem)
-		auth.DELETE("/cart/items/:id", handlers.RemoveFromCart)
-		auth.DELETE("/cart", handlers.ClearCart)
-		
-		// Checkout route
-		auth.POST("/checkout", handlers.Checkout)
+		// Wishlist and save-for-later lists (:list is wishlist or save-for-later)
+		auth.GET("/lists/:list", handlers.GetSavedItems)
+		auth.POST("/lists/:list/items", handlers.AddSavedItem)
+		auth.DELETE("/lists/:list/items/:id", handlers.RemoveSavedItem)
+		auth.POST("/lists/:list/items/:id/move-to-cart", handlers.MoveSavedItemToCart)
+		auth.POST("/cart/items/:id/move-to/:list", handlers.MoveCartItemToList)
 		
 		// Order routes
 		auth.GET("/orders", handlers.GetOrders)
 		auth.GET("/orders/:id", handlers.GetOrderDetails)
+		auth.POST("/orders/:id/reorder", handlers.ReorderOrder)
+		auth.POST("/orders/claim", handlers.ClaimGuestOrder)
 
 		 // Shipping address routes
     	auth.GET("/shipping-addresses", handlers.GetShippingAddresses)
@@ -59,9 +131,6 @@ func main() {
    		auth.POST("/shipping-addresses", handlers.CreateShippingAddress)
     	auth.PUT("/shipping-addresses/:id", handlers.UpdateShippingAddress)
     	auth.DELETE("/shipping-addresses/:id", handlers.DeleteShippingAddress)
-
-		// Protected routes for managing sizes
-		auth.PUT("/products/:id/sizes", handlers.UpdateProductSizes)
 	}
 
 	// Admin-only routes
@@ -71,7 +140,30 @@ func main() {
 		// Product management
 		admin.POST("/products", handlers.CreateProduct)
 		admin.PUT("/products/:id", handlers.UpdateProduct)
-		admin.DELETE("/products/:id", handlers.DeleteProduct)
+		admin.DELETE("/products/:id", handlers.ArchiveProduct) // Deleting archives, keeping order history intact
+		admin.GET("/products/archived", handlers.GetArchivedProducts)
+		admin.GET("/products/:id/preview", handlers.PreviewProduct)
+		admin.POST("/products/import", handlers.ImportProducts)
+		admin.GET("/products/imports/:id", handlers.GetProductImport)
+		admin.GET("/products/export", handlers.ExportProducts)
+		admin.POST("/products/:id/archive", handlers.ArchiveProduct)
+		admin.POST("/products/:id/restore", handlers.RestoreProduct)
+		admin.PUT("/products/:id/categories", handlers.UpdateProductCategories)
+		admin.PUT("/products/:id/variants", handlers.UpdateProductVariants)
+		admin.POST("/products/:id/images", handlers.UploadProductImages)
+		admin.PUT("/products/:id/images/order", handlers.ReorderProductImages)
+		admin.PUT("/products/:id/images/:imageId", handlers.UpdateProductImage)
+		admin.DELETE("/products/:id/images/:imageId", handlers.DeleteProductImage)
+
+		// Category and collection management
+		admin.POST("/categories", handlers.CreateCategory)
+		admin.PUT("/categories/:id", handlers.UpdateCategory)
+		admin.DELETE("/categories/:id", handlers.DeleteCategory)
+		admin.GET("/collections", handlers.GetAllCollections)
+		admin.POST("/collections", handlers.CreateCollection)
+		admin.PUT("/collections/:id", handlers.UpdateCollection)
+		admin.DELETE("/collections/:id", handlers.DeleteCollection)
+		admin.PUT("/collections/:id/products", handlers.UpdateCollectionProducts)
 		
 		// Admin user management
 		admin.POST("/users", handlers.CreateAdmin)
@@ -79,10 +171,41 @@ func main() {
 		// Admin order management
     	admin.GET("/orders", handlers.GetAllOrders)
     	admin.PUT("/orders/:id/status", handlers.UpdateOrderStatus)
-		// Size management
-		admin.POST("/sizes", handlers.CreateSize)
-		admin.PUT("/sizes/:id", handlers.UpdateSize)
-		admin.DELETE("/sizes/:id", handlers.DeleteSize)
+
+		// Coupon management
+		admin.GET("/coupons", handlers.GetAllCoupons)
+		admin.POST("/coupons", handlers.CreateCoupon)
+		admin.PUT("/coupons/:id", handlers.UpdateCoupon)
+		admin.DELETE("/coupons/:id", handlers.DeleteCoupon)
+
+		// Tax class management
+		admin.GET("/tax-classes", handlers.GetAllTaxClasses)
+		admin.POST("/tax-classes", handlers.CreateTaxClass)
+		admin.PUT("/tax-classes/:id", handlers.UpdateTaxClass)
+		admin.DELETE("/tax-classes/:id", handlers.DeleteTaxClass)
+
+		// Shipping method, zone and rate management
+		admin.GET("/shipping-methods", handlers.GetAllShippingMethods)
+		admin.POST("/shipping-methods", handlers.CreateShippingMethod)
+		admin.PUT("/shipping-methods/:id", handlers.UpdateShippingMethod)
+		admin.DELETE("/shipping-methods/:id", handlers.DeleteShippingMethod)
+		admin.PUT("/shipping-methods/:id/rates", handlers.UpdateShippingRates)
+		admin.GET("/shipping-zones", handlers.GetShippingZones)
+		admin.POST("/shipping-zones", handlers.CreateShippingZone)
+		admin.PUT("/shipping-zones/:id", handlers.UpdateShippingZone)
+		admin.DELETE("/shipping-zones/:id", handlers.DeleteShippingZone)
+
+		// Currency, exchange rate and price override management
+		admin.GET("/currencies", handlers.GetAllCurrencies)
+		admin.PUT("/currencies/:code", handlers.SaveCurrency)
+		admin.DELETE("/currencies/:code", handlers.DeleteCurrency)
+		admin.PUT("/products/:id/prices", handlers.UpdateProductPrices)
+
+		// Abandoned cart reminder results
+		admin.GET("/abandoned-carts/stats", handlers.GetAbandonedCartStats)
+
+		// Payment service monitoring
+		admin.GET("/metrics/payment", handlers.GetPaymentMetrics)
 
 
 	}
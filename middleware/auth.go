@@ -46,6 +46,19 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware authenticates requests that send a token and lets
+// requests without one through as guests
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	authRequired := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authRequired(c)
+	}
+}
+
 // AdminRequired ensures user has admin role
 func AdminRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
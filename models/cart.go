@@ -18,19 +18,97 @@ type CartItem struct {
 	ID        int       `json:"id"`
 	CartID    int       `json:"cart_id"`
 	ProductID int       `json:"product_id"`
+	VariantID *int      `json:"variant_id,omitempty"`
+	SKU       string    `json:"sku,omitempty"`
+	VariantTitle string `json:"variant_title,omitempty"`
 	Product   Product   `json:"product,omitempty"`
 	Quantity  int       `json:"quantity"`
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"` // When the stock held for this item is released
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // CartSummary provides a summary of the cart with totals
 type CartSummary struct {
-	CartID      int       `json:"cart_id"`
-	ItemCount   int       `json:"item_count"`
-	TotalItems  int       `json:"total_items"`
-	TotalAmount float64   `json:"total_amount"`
-	Items       []CartItem `json:"items"`
+	CartID           int            `json:"cart_id"`
+	CartToken        string         `json:"cart_token,omitempty"` // Set for guest carts; send it back as X-Cart-Token
+	Currency         string         `json:"currency"`
+	ItemCount        int            `json:"item_count"`
+	TotalItems       int            `json:"total_items"`
+	TotalAmount      Money          `json:"total_amount"`
+	Tax              Money          `json:"tax"`
+	TaxBreakdown     []TaxBreakdown `json:"tax_breakdown"`
+	PricesIncludeTax bool           `json:"prices_include_tax"`
+	Items            []CartItem     `json:"items"`
+}
+
+// CartMergeAdjustment reports a guest cart line whose quantity had to be
+// reduced to fit the stock left when it was merged into the user's cart, or
+// that was dropped (Quantity 0) because the product is no longer on sale
+type CartMergeAdjustment struct {
+	ProductID         int  `json:"product_id"`
+	VariantID         *int `json:"variant_id,omitempty"`
+	RequestedQuantity int  `json:"requested_quantity"`
+	Quantity          int  `json:"quantity"`
+}
+
+// Reorder line statuses
+const (
+	ReorderAdded   = "added"
+	ReorderReduced = "reduced"
+	ReorderSkipped = "skipped"
+)
+
+// ReorderLine reports what happened to one line of a past order when it was
+// copied back into the cart
+type ReorderLine struct {
+	ProductID         int    `json:"product_id"`
+	VariantID         *int   `json:"variant_id,omitempty"`
+	Name              string `json:"name"`
+	VariantTitle      string `json:"variant_title,omitempty"`
+	RequestedQuantity int    `json:"requested_quantity"`
+	Quantity          int    `json:"quantity"` // Quantity actually added to the cart
+	Status            string `json:"status"`
+	Reason            string `json:"reason,omitempty"`
+	OrderedPrice      Money  `json:"ordered_price"`
+	CurrentPrice      Money  `json:"current_price"`
+	PriceChanged      bool   `json:"price_changed"`
+}
+
+// Cart batch operations and their result statuses
+const (
+	CartOpAdd    = "add"
+	CartOpUpdate = "update"
+	CartOpRemove = "remove"
+
+	CartOpOK         = "ok"
+	CartOpFailed     = "failed"
+	CartOpNotApplied = "not_applied"
+)
+
+// CartOperation is one change in a cart batch. Add uses product_id, variant_id
+// and quantity; update uses item_id and quantity (zero removes the item);
+// remove uses item_id.
+type CartOperation struct {
+	Op        string `json:"op" binding:"required,oneof=add update remove"`
+	ItemID    int    `json:"item_id"`
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CartBatchInput holds the operations for PATCH /cart, applied in order
+type CartBatchInput struct {
+	Operations []CartOperation `json:"operations" binding:"required,min=1,max=100,dive"`
+}
+
+// CartOperationResult reports what happened to one operation in a cart batch
+type CartOperationResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ItemID int    `json:"item_id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
 
 // CartItemInput holds data for adding/updating cart items
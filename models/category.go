@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Category groups products for navigation. Categories nest through ParentID.
+type Category struct {
+	ID           int        `json:"id"`
+	ParentID     *int       `json:"parent_id"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	Description  string     `json:"description"`
+	DisplayOrder int        `json:"display_order"`
+	Children     []Category `json:"children,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// CategoryInput is used for creating/updating categories. The slug is made
+// from the name when left empty.
+type CategoryInput struct {
+	ParentID     *int   `json:"parent_id"`
+	Name         string `json:"name" binding:"required"`
+	Slug         string `json:"slug"`
+	Description  string `json:"description"`
+	DisplayOrder int    `json:"display_order"`
+}
+
+// CategoryRef is the short form of a category shown on products
+type CategoryRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// ProductCategoriesInput replaces the categories a product belongs to
+type ProductCategoriesInput struct {
+	CategoryIDs []int `json:"category_ids"`
+}
+
+// Collection is a curated, manually ordered list of products
+type Collection struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active"`
+	Products    []Product `json:"products,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CollectionInput is used for creating/updating collections
+type CollectionInput struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// CollectionProductsInput sets a collection's products in display order
+type CollectionProductsInput struct {
+	ProductIDs []int `json:"product_ids"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// CheckoutInput holds the shipping information shared by checkout and quotes
+type CheckoutInput struct {
+	Email             string                `json:"email"` // Required when checking out as a guest
+	ShippingAddressID *int                  `json:"shipping_address_id"`
+	ShippingAddress   *ShippingAddressInput `json:"shipping_address"`
+	ShippingMethodID  *int                  `json:"shipping_method_id"`
+}
+
+// QuoteLine is a priced cart line
+type QuoteLine struct {
+	ProductID    int     `json:"product_id"`
+	VariantID    *int    `json:"variant_id,omitempty"`
+	SKU          string  `json:"sku,omitempty"`
+	VariantTitle string  `json:"variant_title,omitempty"`
+	Name         string  `json:"name"`
+	Quantity     int     `json:"quantity"`
+	UnitPrice    Money   `json:"unit_price"`
+	LineTotal    Money   `json:"line_total"`
+	TaxClass     string  `json:"tax_class"`
+	TaxRate      float64 `json:"tax_rate"`
+	TaxAmount    Money   `json:"tax_amount"`
+	Weight       float64 `json:"-"` // Unit weight in kilograms
+	CurrentStock int     `json:"-"`
+}
+
+// CheckoutQuote is the full price breakdown for a cart
+type CheckoutQuote struct {
+	Currency         string            `json:"currency"`
+	ExchangeRate     float64           `json:"exchange_rate"` // Rate used for amounts without a currency override
+	Lines            []QuoteLine       `json:"lines"`
+	Subtotal         Money             `json:"subtotal"`
+	Discounts        []AppliedDiscount `json:"discounts"`
+	Discount         Money             `json:"discount"`
+	ShippingMethodID *int              `json:"shipping_method_id,omitempty"`
+	ShippingMethod   string            `json:"shipping_method,omitempty"`
+	TotalWeight      float64           `json:"total_weight"`
+	Shipping         Money             `json:"shipping"`
+	ShippingDiscount Money             `json:"shipping_discount"` // Part of Discount that offsets Shipping
+	Tax              Money             `json:"tax"`
+	TaxBreakdown     []TaxBreakdown    `json:"tax_breakdown"`
+	PricesIncludeTax bool              `json:"prices_include_tax"`
+	GrandTotal       Money             `json:"grand_total"`
+}
+
+// FromBase converts an amount configured in the base currency, such as a
+// shipping rate or a fixed coupon value, into the quote's currency
+func (q *CheckoutQuote) FromBase(m Money) Money {
+	if q.ExchangeRate == 0 || q.ExchangeRate == 1 {
+		return m
+	}
+	return m.Convert(q.ExchangeRate)
+}
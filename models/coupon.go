@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// Coupon types
+const (
+	CouponPercentage   = "percentage"
+	CouponFixedAmount  = "fixed_amount"
+	CouponFreeShipping = "free_shipping"
+	CouponBuyXGetY     = "buy_x_get_y"
+)
+
+// Coupon represents an admin-managed discount code
+type Coupon struct {
+	ID                int        `json:"id"`
+	Code              string     `json:"code"`
+	Description       string     `json:"description"`
+	Type              string     `json:"type"`
+	Value             Money      `json:"value"` // Amount for fixed_amount coupons; for percentage coupons 12.50 means 12.5%
+	MinSpend          Money      `json:"min_spend"`
+	ProductID         *int       `json:"product_id,omitempty"` // Restricts the coupon to one product
+	VariantID         *int       `json:"variant_id,omitempty"` // Restricts the coupon to one variant
+	BuyQuantity       int        `json:"buy_quantity,omitempty"`
+	GetQuantity       int        `json:"get_quantity,omitempty"`
+	UsageLimit        *int       `json:"usage_limit,omitempty"`          // Total redemptions allowed
+	UsageLimitPerUser *int       `json:"usage_limit_per_user,omitempty"` // Redemptions allowed per user
+	StartsAt          *time.Time `json:"starts_at,omitempty"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// CouponInput is used for creating/updating coupons
+type CouponInput struct {
+	Code              string     `json:"code" binding:"required"`
+	Description       string     `json:"description"`
+	Type              string     `json:"type" binding:"required"`
+	Value             Money      `json:"value"`
+	MinSpend          Money      `json:"min_spend"`
+	ProductID         *int       `json:"product_id"`
+	VariantID         *int       `json:"variant_id"`
+	BuyQuantity       int        `json:"buy_quantity"`
+	GetQuantity       int        `json:"get_quantity"`
+	UsageLimit        *int       `json:"usage_limit"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user"`
+	StartsAt          *time.Time `json:"starts_at"`
+	EndsAt            *time.Time `json:"ends_at"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+// AppliedDiscount is a discount applied to a quote or recorded on an order
+type AppliedDiscount struct {
+	CouponID    int    `json:"coupon_id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+}
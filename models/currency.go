@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Currency is a currency the store displays and charges in. Product prices
+// are stored in the base currency and converted with ExchangeRate unless a
+// per-product override exists. Every currency is assumed to have two minor
+// digits, which holds for THB and its neighbours.
+type Currency struct {
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	Symbol       string    `json:"symbol"`
+	ExchangeRate float64   `json:"exchange_rate"` // Units of this currency per unit of the base currency
+	IsBase       bool      `json:"is_base"`
+	IsActive     bool      `json:"is_active"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// FromBase converts an amount in the base currency into this currency
+func (c Currency) FromBase(m Money) Money {
+	if c.IsBase {
+		return m
+	}
+	return m.Convert(c.ExchangeRate)
+}
+
+// CurrencyInput is used for creating/updating currencies
+type CurrencyInput struct {
+	Name         string  `json:"name" binding:"required"`
+	Symbol       string  `json:"symbol"`
+	ExchangeRate float64 `json:"exchange_rate" binding:"required"`
+	IsActive     *bool   `json:"is_active"`
+}
+
+// ProductPrice overrides a product's converted price in one currency
+type ProductPrice struct {
+	Currency string `json:"currency" binding:"required"`
+	Price    Money  `json:"price"`
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (satang for THB), so sums never drift.
+//
+// Rounding rule: whenever a calculation produces a fraction of a minor unit
+// (percentages, tax, proportional shares) it is rounded half away from zero
+// to the nearest minor unit. Amounts are written to JSON as decimal numbers
+// with two places (e.g. 123.45) and to the database as DECIMAL strings.
+type Money int64
+
+// ErrInvalidMoney is returned when an amount cannot be parsed
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ParseMoney parses a decimal string such as "123.45" without going through
+// float64. Digits beyond the second decimal place are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidMoney
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidMoney
+	}
+	if strings.ContainsAny(s, "eE") {
+		// Exponent notation is rare enough to go through float64
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, ErrInvalidMoney
+		}
+		m := MoneyFromFloat(f)
+		if negative {
+			m = -m
+		}
+		return m, nil
+	}
+
+	var units int64
+	for _, r := range whole {
+		if r < '0' || r > '9' {
+			return 0, ErrInvalidMoney
+		}
+		units = units*10 + int64(r-'0')
+	}
+
+	var cents int64
+	for i, r := range frac {
+		if r < '0' || r > '9' {
+			return 0, ErrInvalidMoney
+		}
+		if i < 2 {
+			cents = cents*10 + int64(r-'0')
+		}
+	}
+	if len(frac) == 1 {
+		cents *= 10
+	}
+
+	m := Money(units*100 + cents)
+	if len(frac) > 2 && frac[2] >= '5' {
+		m++
+	}
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// MoneyFromFloat converts a float amount, rounding half away from zero
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * 100))
+}
+
+// Float64 returns the amount in major units. Use it only for display or
+// for services that require a float.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount with two decimal places
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// Mul multiplies the amount by a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent returns rate percent of the amount, rounded to the nearest minor unit
+func (m Money) Percent(rate float64) Money {
+	return Money(math.Round(float64(m) * rate / 100))
+}
+
+// Convert multiplies the amount by an exchange rate, rounded to the nearest minor unit
+func (m Money) Convert(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// Share returns the part of the amount proportional to part/whole, rounded
+// to the nearest minor unit
+func (m Money) Share(part, whole Money) Money {
+	if whole == 0 {
+		return 0
+	}
+	return Money(math.Round(float64(m) * float64(part) / float64(whole)))
+}
+
+// MarshalJSON writes the amount as a decimal number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		*m = MoneyFromFloat(v)
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+// Value writes the amount as a DECIMAL string
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -4,24 +4,50 @@ import (
 	"time"
 )
 
+// Product statuses. Only published products are shown to customers.
+const (
+    ProductDraft     = "draft"
+    ProductScheduled = "scheduled" // Published automatically at PublishAt
+    ProductPublished = "published"
+    ProductArchived  = "archived"
+)
+
 // Product represents product data in the system
 type Product struct {
     ID          int           `json:"id"`
     Name        string        `json:"name"`
     Description string        `json:"description"`
-    Price       float64       `json:"price"`
-    Stock       int           `json:"stock"` // Total stock across all sizes
-    Sizes       []ProductSize `json:"sizes,omitempty"`
+    Price       Money         `json:"price"`
+    Currency    string        `json:"currency,omitempty"` // Currency of Price when converted for display
+    Prices      []ProductPrice `json:"prices,omitempty"`  // Per-currency price overrides
+    Stock       int           `json:"stock"` // Total stock across all variants
+    Weight      float64       `json:"weight"` // Kilograms, used for shipping rates
+    Options     []ProductOption `json:"options,omitempty"`
+    Variants    []ProductVariant `json:"variants,omitempty"`
+    Categories  []CategoryRef `json:"categories,omitempty"`
+    Images      []ProductImage `json:"images,omitempty"`
+    TaxClassID  *int          `json:"tax_class_id,omitempty"` // Default tax rate when nil
     CreatedBy   int           `json:"created_by"`
     CreatedAt   time.Time     `json:"created_at"`
     UpdatedAt   time.Time     `json:"updated_at"`
+    Status      string        `json:"status,omitempty"`
+    PublishAt   *time.Time    `json:"publish_at,omitempty"`
+    UnpublishAt *time.Time    `json:"unpublish_at,omitempty"` // Goes back to draft at this time
+    ArchivedAt  *time.Time    `json:"archived_at,omitempty"`
 }
 
-// ProductInput with sizes
+// ProductInput with options and variants. Stock is only used for products
+// without variants; otherwise it is the total of the variant stock.
 type ProductInput struct {
     Name        string             `json:"name" binding:"required"`
     Description string             `json:"description"`
-    Price       float64            `json:"price" binding:"required"`
-    Stock       int                 `json:"stock"`
-    Sizes       []ProductSizeInput `json:"sizes"`
+    Price       Money              `json:"price" binding:"required"`
+    Stock       int                 `json:"stock" binding:"min=0"`
+    Weight      float64            `json:"weight"`
+    Options     []ProductOptionInput  `json:"options" binding:"dive"`
+    Variants    []ProductVariantInput `json:"variants" binding:"dive"`
+    TaxClassID  *int               `json:"tax_class_id"`
+    Status      string             `json:"status" binding:"omitempty,oneof=draft scheduled published"` // New products default to draft
+    PublishAt   *time.Time         `json:"publish_at"` // Required when scheduled
+    UnpublishAt *time.Time         `json:"unpublish_at"`
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductImage is a picture of a product. Thumbnails maps each thumbnail
+// size name to its URL.
+type ProductImage struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+	AltText     string            `json:"alt_text"`
+	Position    int               `json:"position"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	ContentType string            `json:"content_type"`
+	SizeBytes   int               `json:"size_bytes"`
+	CreatedAt   time.Time         `json:"created_at"`
+
+	StorageKey string `json:"-"`
+}
+
+// ProductImageInput updates an image's alt text
+type ProductImageInput struct {
+	AltText string `json:"alt_text"`
+}
+
+// ProductImageOrderInput sets the display order of a product's images
+type ProductImageOrderInput struct {
+	ImageIDs []int `json:"image_ids" binding:"required"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// Product import statuses
+const (
+	ImportQueued  = "queued"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// ProductImportRow is one line of a product import or export: a variant,
+// found by SKU, with the fields of the product it belongs to. A row without
+// a SKU is a product without variants. Blank fields leave existing values as
+// they are.
+type ProductImportRow struct {
+	ProductID    *int              `json:"product_id,omitempty"` // Rows for a new product are grouped by name
+	SKU          string            `json:"sku,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	Description  *string           `json:"description,omitempty"`
+	Price        *Money            `json:"price,omitempty"`
+	Weight       *float64          `json:"weight,omitempty"`
+	Status       string            `json:"status,omitempty"` // draft or published
+	Barcode      *string           `json:"barcode,omitempty"`
+	VariantPrice *Money            `json:"variant_price,omitempty"`
+	Stock        *int              `json:"stock,omitempty"`
+	Options      map[string]string `json:"options,omitempty"` // Option name to value, e.g. {"Size": "M"}
+}
+
+// ProductImportError reports a product that could not be imported. Row is
+// the first line of the product in the file, counting the CSV header as 1.
+type ProductImportError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// ProductImportReport sums up what an import changed, or would change on a
+// dry run
+type ProductImportReport struct {
+	Rows            int                  `json:"rows"`
+	ProductsCreated int                  `json:"products_created"`
+	ProductsUpdated int                  `json:"products_updated"`
+	VariantsCreated int                  `json:"variants_created"`
+	VariantsUpdated int                  `json:"variants_updated"`
+	Errors          []ProductImportError `json:"errors"`
+}
+
+// ProductImport is an uploaded import file and how far it has got
+type ProductImport struct {
+	ID         int                  `json:"id"`
+	Format     string               `json:"format"`
+	DryRun     bool                 `json:"dry_run"`
+	Status     string               `json:"status"`
+	Report     *ProductImportReport `json:"report,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	CreatedBy  int                  `json:"created_by"`
+	CreatedAt  time.Time            `json:"created_at"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Saved item lists
+const (
+	ListWishlist     = "wishlist"
+	ListSaveForLater = "save-for-later"
+)
+
+// SavedItem is a product (and variant) a user parked on their wishlist or save-for-later list
+type SavedItem struct {
+	ID             int       `json:"id"`
+	List           string    `json:"list"`
+	ProductID      int       `json:"product_id"`
+	VariantID      *int      `json:"variant_id,omitempty"`
+	VariantTitle   string    `json:"variant_title,omitempty"`
+	Quantity       int       `json:"quantity"`
+	Product        Product   `json:"product"`
+	PriceWhenAdded Money     `json:"price_when_added"`
+	PriceDropped   bool      `json:"price_dropped"` // The product is cheaper now than when it was saved
+	PriceDrop      Money     `json:"price_drop,omitempty"`
+	InStock        bool      `json:"in_stock"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SavedItemInput is used for adding an item to a list
+type SavedItemInput struct {
+	ProductID int  `json:"product_id" binding:"required"`
+	VariantID *int `json:"variant_id"`
+	Quantity  int  `json:"quantity"`
+}
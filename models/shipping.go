@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// Shipping rate types
+const (
+	ShippingRateFlat   = "flat"   // One price per zone regardless of weight
+	ShippingRateWeight = "weight" // Price by total cart weight bracket
+)
+
+// ShippingMethod is a delivery option the customer picks at checkout
+type ShippingMethod struct {
+	ID                    int            `json:"id"`
+	Code                  string         `json:"code"`
+	Name                  string         `json:"name"`
+	Description           string         `json:"description"`
+	RateType              string         `json:"rate_type"`
+	FreeShippingThreshold *Money         `json:"free_shipping_threshold,omitempty"`
+	IsActive              bool           `json:"is_active"`
+	DisplayOrder          int            `json:"display_order"`
+	Rates                 []ShippingRate `json:"rates,omitempty"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+}
+
+// ShippingMethodInput is used for creating/updating shipping methods
+type ShippingMethodInput struct {
+	Code                  string `json:"code" binding:"required"`
+	Name                  string `json:"name" binding:"required"`
+	Description           string `json:"description"`
+	RateType              string `json:"rate_type" binding:"required"`
+	FreeShippingThreshold *Money `json:"free_shipping_threshold"`
+	IsActive              *bool  `json:"is_active"`
+	DisplayOrder          int    `json:"display_order"`
+}
+
+// ShippingZone matches shipping addresses by country, state and postal code prefix
+type ShippingZone struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	Country          string    `json:"country"`
+	State            string    `json:"state,omitempty"`              // Empty matches any state
+	PostalCodePrefix string    `json:"postal_code_prefix,omitempty"` // Empty matches any postal code
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ShippingZoneInput is used for creating/updating shipping zones
+type ShippingZoneInput struct {
+	Name             string `json:"name" binding:"required"`
+	Country          string `json:"country" binding:"required"`
+	State            string `json:"state"`
+	PostalCodePrefix string `json:"postal_code_prefix"`
+}
+
+// ShippingRate is the price of a method in a zone for a weight bracket
+type ShippingRate struct {
+	ID        int      `json:"id"`
+	MethodID  int      `json:"method_id"`
+	ZoneID    *int     `json:"zone_id,omitempty"`    // Nil applies to every zone
+	MinWeight float64  `json:"min_weight"`           // Kilograms, inclusive
+	MaxWeight *float64 `json:"max_weight,omitempty"` // Kilograms, exclusive; nil means no upper bound
+	Rate      Money    `json:"rate"`
+}
+
+// ShippingRateInput is used for replacing a method's rate table
+type ShippingRateInput struct {
+	ZoneID    *int     `json:"zone_id"`
+	MinWeight float64  `json:"min_weight"`
+	MaxWeight *float64 `json:"max_weight"`
+	Rate      Money    `json:"rate"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// TaxClass groups products that share a tax rate
+type TaxClass struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Rate      float64   `json:"rate"` // Percent, e.g. 7 for Thai VAT
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TaxClassInput is used for creating/updating tax classes
+type TaxClassInput struct {
+	Name string   `json:"name" binding:"required"`
+	Rate *float64 `json:"rate" binding:"required"`
+}
+
+// TaxBreakdown is the tax collected at one rate across a cart or order
+type TaxBreakdown struct {
+	TaxClass      string  `json:"tax_class"`
+	Rate          float64 `json:"rate"`
+	TaxableAmount Money   `json:"taxable_amount"`
+	TaxAmount     Money   `json:"tax_amount"`
+}
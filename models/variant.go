@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductOption is a way a product varies, such as Size or Color, with the
+// values it comes in
+type ProductOption struct {
+	ID       int                  `json:"id"`
+	Name     string               `json:"name"`
+	Position int                  `json:"position"`
+	Values   []ProductOptionValue `json:"values"`
+}
+
+// ProductOptionValue is one value of a product option, such as "M" or "Red"
+type ProductOptionValue struct {
+	ID       int    `json:"id"`
+	Value    string `json:"value"`
+	Position int    `json:"position"`
+}
+
+// ProductVariant is one purchasable combination of option values, with its
+// own SKU and stock
+type ProductVariant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Barcode   *string           `json:"barcode,omitempty"`
+	Price     *Money            `json:"price,omitempty"` // Replaces the product price when set
+	Stock     int               `json:"stock"`
+	Options   map[string]string `json:"options"` // Option name to value, e.g. {"Size": "M", "Color": "Red"}
+	Title     string            `json:"title"`   // Option values in option order, e.g. "M / Red"
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ProductSize is a variant of a product's Size option in the shape the
+// retired sizes endpoints used. ID is the variant ID and SizeID the ID of the
+// option value.
+type ProductSize struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	SizeID    int       `json:"size_id"`
+	SizeName  string    `json:"size_name"`
+	Stock     int       `json:"stock"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProductOptionInput names an option and its values in display order
+type ProductOptionInput struct {
+	Name   string   `json:"name" binding:"required"`
+	Values []string `json:"values" binding:"required,min=1"`
+}
+
+// ProductVariantInput describes a variant. Options gives one value for each
+// of the product's options; a product without options has at most one variant.
+type ProductVariantInput struct {
+	SKU     string            `json:"sku" binding:"required"`
+	Barcode *string           `json:"barcode"`
+	Price   *Money            `json:"price"` // Base currency
+	Stock   int               `json:"stock" binding:"min=0"`
+	Options map[string]string `json:"options"`
+}
+
+// ProductVariantsInput replaces a product's options and variants. Variants
+// are matched to existing ones by SKU, so carts holding them keep working.
+type ProductVariantsInput struct {
+	Options  []ProductOptionInput  `json:"options" binding:"dive"`
+	Variants []ProductVariantInput `json:"variants" binding:"dive"`
+}
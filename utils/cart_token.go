@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewCartToken returns an opaque, unguessable token that identifies a guest cart
+func NewCartToken() string {
+	var random [32]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(random[:])
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ImageConfig holds the limits for uploaded images
+type ImageConfig struct {
+	MaxBytes  int64 // Largest file accepted
+	MaxPixels int   // Largest width × height accepted, to stop decompression bombs
+}
+
+// Images is the active image upload configuration. MAX_IMAGE_UPLOAD_MB sets
+// the file size limit (default 5).
+var Images = loadImageConfig()
+
+func loadImageConfig() ImageConfig {
+	config := ImageConfig{MaxBytes: 5 << 20, MaxPixels: 40_000_000}
+
+	if mb, err := strconv.Atoi(os.Getenv("MAX_IMAGE_UPLOAD_MB")); err == nil && mb > 0 {
+		config.MaxBytes = int64(mb) << 20
+	}
+
+	return config
+}
+
+// ImageTypes maps the accepted image MIME types to file extensions
+var ImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ThumbnailSize is a named thumbnail that fits in a MaxDimension square
+type ThumbnailSize struct {
+	Name         string
+	MaxDimension int
+}
+
+// ThumbnailSizes are generated for every uploaded image
+var ThumbnailSizes = []ThumbnailSize{
+	{"small", 150},
+	{"medium", 400},
+	{"large", 800},
+}
+
+// ImageError is an uploaded file that isn't an acceptable image
+type ImageError struct {
+	Message string
+}
+
+func (e *ImageError) Error() string {
+	return e.Message
+}
+
+// UploadedImage is a validated, decoded image
+type UploadedImage struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+
+	pixels *image.RGBA // Decoded once and shared by every thumbnail
+}
+
+// ReadImage reads and validates an upload: the size limit, a content type
+// sniffed from the bytes rather than trusted from the client, and the pixel
+// limit. Problems with the file are reported as *ImageError.
+func ReadImage(r io.Reader) (*UploadedImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, Images.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > Images.MaxBytes {
+		return nil, &ImageError{fmt.Sprintf("image is larger than %d MB", Images.MaxBytes>>20)}
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := ImageTypes[contentType]; !ok {
+		return nil, &ImageError{"image must be a JPEG, PNG or GIF"}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageError{"image could not be read"}
+	}
+	if config.Width*config.Height > Images.MaxPixels {
+		return nil, &ImageError{"image dimensions are too large"}
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &ImageError{"image could not be read"}
+	}
+
+	return &UploadedImage{
+		Data:        data,
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		pixels:      toRGBA(decoded),
+	}, nil
+}
+
+// Release drops the image's bytes and pixels once it has been stored, so
+// uploads handled one after another don't all stay in memory
+func (img *UploadedImage) Release() {
+	img.Data = nil
+	img.pixels = nil
+}
+
+// toRGBA converts a decoded image to RGBA with its origin at 0,0, which is
+// what scaleDown reads
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// ThumbnailType is the content type and extension thumbnails of an image
+// type are stored as: JPEG stays JPEG, anything else becomes PNG so
+// transparency is kept
+func ThumbnailType(contentType string) (string, string) {
+	if contentType == "image/jpeg" {
+		return "image/jpeg", ".jpg"
+	}
+	return "image/png", ".png"
+}
+
+// ThumbnailKey is the storage key of one thumbnail of the image stored at key
+func ThumbnailKey(key, contentType, size string) string {
+	_, ext := ThumbnailType(contentType)
+	if dot := strings.LastIndex(key, "."); dot > strings.LastIndex(key, "/") {
+		key = key[:dot]
+	}
+	return key + "_" + size + ext
+}
+
+// Thumbnail scales the image to fit in a maxDimension square, keeping its
+// aspect ratio. Images already small enough are not enlarged.
+func (img *UploadedImage) Thumbnail(maxDimension int) ([]byte, error) {
+	width, height := img.Width, img.Height
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			height = max(1, height*maxDimension/width)
+			width = maxDimension
+		} else {
+			width = max(1, width*maxDimension/height)
+			height = maxDimension
+		}
+	}
+
+	scaled := scaleDown(img.pixels, width, height)
+
+	var buf bytes.Buffer
+	var err error
+	if contentType, _ := ThumbnailType(img.ContentType); contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, scaled)
+	}
+	return buf.Bytes(), err
+}
+
+// scaleDown resizes rgba to width × height by averaging the source pixels
+// that fall in each destination pixel
+func scaleDown(rgba *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := rgba.Rect.Dx(), rgba.Rect.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, (y+1)*srcHeight/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, (x+1)*srcWidth/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			count := 0
+			for sy := y0; sy < y1; sy++ {
+				offset := sy*rgba.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(rgba.Pix[offset+c])
+					}
+					offset += 4
+					count++
+				}
+			}
+
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+
+	return dst
+}
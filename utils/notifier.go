@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notification is a message for a customer, such as an abandoned cart reminder
+type Notification struct {
+	Kind    string                 `json:"kind"` // e.g. "abandoned_cart"
+	To      string                 `json:"to"`
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier delivers notifications. Swap Notifications for a real email or
+// messaging integration; the built-in sinks are meant for development.
+type Notifier interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the application log
+type LogNotifier struct{}
+
+// Send logs the notification
+func (LogNotifier) Send(ctx context.Context, n Notification) error {
+	log.Printf("notification %s to %s: %s", n.Kind, n.To, n.Subject)
+	return nil
+}
+
+// FileNotifier appends notifications to a file as JSON lines
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send appends the notification to the file
+func (f *FileNotifier) Send(ctx context.Context, n Notification) error {
+	record := struct {
+		Notification
+		SentAt time.Time `json:"sent_at"`
+	}{n, time.Now()}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Notifications is the active notifier. Set NOTIFICATIONS_FILE to write
+// notifications to a file; otherwise they are logged.
+var Notifications Notifier = loadNotifier()
+
+func loadNotifier() Notifier {
+	if path := os.Getenv("NOTIFICATIONS_FILE"); path != "" {
+		return &FileNotifier{Path: path}
+	}
+	return LogNotifier{}
+}
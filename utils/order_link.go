@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OrderLinkTTL is how long a signed guest order link stays valid
+const OrderLinkTTL = 90 * 24 * time.Hour
+
+// SignOrderLink returns a token that lets whoever holds it view one order
+// without logging in. It has the form <order id>.<expiry>.<signature>, and is
+// signed separately from login tokens so neither can stand in for the other.
+func SignOrderLink(orderID string) string {
+	payload := orderID + "." + strconv.FormatInt(time.Now().Add(OrderLinkTTL).Unix(), 10)
+	return payload + "." + orderLinkSignature(payload)
+}
+
+// VerifyOrderLink checks a token from SignOrderLink and returns its order ID
+func VerifyOrderLink(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("invalid order link")
+	}
+
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(orderLinkSignature(payload))) {
+		return "", errors.New("invalid order link")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || expiresAt < time.Now().Unix() {
+		return "", errors.New("order link expired")
+	}
+
+	return parts[0], nil
+}
+
+func orderLinkSignature(payload string) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("order-link:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Crockford's base32 alphabet (no I, L, O or U) keeps IDs unambiguous when read aloud
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	ulidMu       sync.Mutex
+	lastULIDTime uint64
+	lastULIDRand [10]byte
+)
+
+// NewOrderID returns a ULID: 48 bits of millisecond timestamp followed by 80
+// random bits, encoded as 26 Crockford base32 characters. IDs sort by creation
+// time, and IDs created in the same millisecond increase monotonically.
+func NewOrderID() string {
+	ulidMu.Lock()
+	defer ulidMu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms == lastULIDTime {
+		incrementRandom(&lastULIDRand)
+	} else {
+		lastULIDTime = ms
+		if _, err := rand.Read(lastULIDRand[:]); err != nil {
+			panic("crypto/rand unavailable: " + err.Error())
+		}
+	}
+
+	var raw [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(raw[:6], ts[2:])
+	copy(raw[6:], lastULIDRand[:])
+
+	return encodeULID(raw)
+}
+
+// NewOrderNumber returns a short, human-friendly order number for receipts,
+// e.g. "250118-7K3F9Q". It is random rather than sequential, so the caller
+// must rely on the unique constraint and retry on the rare collision.
+func NewOrderNumber() string {
+	var random [4]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+
+	// 30 random bits -> 6 base32 characters
+	n := binary.BigEndian.Uint32(random[:]) >> 2
+	suffix := make([]byte, 6)
+	for i := len(suffix) - 1; i >= 0; i-- {
+		suffix[i] = crockford[n&0x1F]
+		n >>= 5
+	}
+
+	return time.Now().Format("060102") + "-" + string(suffix)
+}
+
+// incrementRandom adds one to the 80-bit random component
+func incrementRandom(r *[10]byte) {
+	for i := len(r) - 1; i >= 0; i-- {
+		r[i]++
+		if r[i] != 0 {
+			return
+		}
+	}
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters (the first carries 3 bits)
+func encodeULID(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
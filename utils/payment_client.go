@@ -0,0 +1,338 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPaymentCircuitOpen is returned while the circuit breaker is rejecting calls
+var ErrPaymentCircuitOpen = errors.New("payment service temporarily unavailable")
+
+// PaymentError is returned when the payment service answers with a non-2xx status
+type PaymentError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *PaymentError) Error() string {
+	return "payment service returned error: " + e.Status
+}
+
+// PaymentClient talks to the Java payment service
+type PaymentClient struct {
+	BaseURL      string
+	CallTimeout  time.Duration // Timeout for a single attempt
+	MaxRetries   int           // Extra attempts for idempotent calls
+	RetryBackoff time.Duration // Base delay, doubled on each retry and jittered
+
+	httpClient *http.Client
+	breaker    *circuitBreaker
+	metrics    paymentMetrics
+}
+
+// PaymentMetrics is a snapshot of the payment client counters
+type PaymentMetrics struct {
+	Requests         int64  `json:"requests"`
+	Successes        int64  `json:"successes"`
+	Failures         int64  `json:"failures"`
+	Retries          int64  `json:"retries"`
+	Rejected         int64  `json:"rejected"` // Calls short-circuited by the breaker
+	AvgLatencyMillis int64  `json:"avg_latency_ms"`
+	CircuitState     string `json:"circuit_state"`
+}
+
+type paymentMetrics struct {
+	requests     int64
+	successes    int64
+	failures     int64
+	retries      int64
+	rejected     int64
+	latencyTotal int64 // Nanoseconds across all attempts
+}
+
+// Payment is the shared client used by the handlers
+var Payment = NewPaymentClient(paymentServiceURL())
+
+func paymentServiceURL() string {
+	if url := os.Getenv("PAYMENT_SERVICE_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8088"
+}
+
+// NewPaymentClient creates a payment client with default timeouts, retries and breaker settings
+func NewPaymentClient(baseURL string) *PaymentClient {
+	return &PaymentClient{
+		BaseURL:      baseURL,
+		CallTimeout:  10 * time.Second,
+		MaxRetries:   2,
+		RetryBackoff: 200 * time.Millisecond,
+		httpClient:   &http.Client{},
+		breaker:      newCircuitBreaker(5, 30*time.Second),
+	}
+}
+
+// CreateQR creates a QR payment. It is not idempotent, so it is never retried.
+func (p *PaymentClient) CreateQR(ctx context.Context, request map[string]interface{}) (map[string]interface{}, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := p.do(ctx, http.MethodPost, "/api/payment/create-qr", body, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse payment service response: %w", err)
+	}
+	return response, nil
+}
+
+// Cancel cancels a payment by transaction ID
+func (p *PaymentClient) Cancel(ctx context.Context, transactionID string) error {
+	_, err := p.do(ctx, http.MethodPost, "/api/payment/cancel/"+transactionID, nil, true)
+	return err
+}
+
+// Status returns the payment status reported by the payment service
+func (p *PaymentClient) Status(ctx context.Context, transactionID string) (string, error) {
+	respBody, err := p.do(ctx, http.MethodGet, "/api/payment/status/"+transactionID, nil, true)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to parse payment service response: %w", err)
+	}
+	return response.Status, nil
+}
+
+// Metrics returns a snapshot of the client counters
+func (p *PaymentClient) Metrics() PaymentMetrics {
+	requests := atomic.LoadInt64(&p.metrics.requests)
+	retries := atomic.LoadInt64(&p.metrics.retries)
+
+	var avgLatency int64
+	if attempts := requests + retries; attempts > 0 {
+		avgLatency = atomic.LoadInt64(&p.metrics.latencyTotal) / attempts / int64(time.Millisecond)
+	}
+
+	return PaymentMetrics{
+		Requests:         requests,
+		Successes:        atomic.LoadInt64(&p.metrics.successes),
+		Failures:         atomic.LoadInt64(&p.metrics.failures),
+		Retries:          retries,
+		Rejected:         atomic.LoadInt64(&p.metrics.rejected),
+		AvgLatencyMillis: avgLatency,
+		CircuitState:     p.breaker.state(),
+	}
+}
+
+// do performs a request, retrying idempotent calls on network errors and 5xx responses
+func (p *PaymentClient) do(ctx context.Context, method, path string, body []byte, idempotent bool) ([]byte, error) {
+	atomic.AddInt64(&p.metrics.requests, 1)
+
+	attempts := 1
+	if idempotent {
+		attempts += p.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			atomic.AddInt64(&p.metrics.retries, 1)
+			if err := sleepWithJitter(ctx, p.RetryBackoff<<(attempt-1)); err != nil {
+				lastErr = err
+				break
+			}
+		}
+
+		if !p.breaker.allow() {
+			atomic.AddInt64(&p.metrics.rejected, 1)
+			lastErr = ErrPaymentCircuitOpen
+			break
+		}
+
+		respBody, err := p.attempt(ctx, method, path, body)
+		if err == nil {
+			p.breaker.success()
+			atomic.AddInt64(&p.metrics.successes, 1)
+			return respBody, nil
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the service's health
+			p.breaker.release()
+			break
+		}
+		if !isRetryable(err) {
+			// 4xx responses mean the service is healthy, so they don't trip the breaker
+			var paymentErr *PaymentError
+			if errors.As(err, &paymentErr) {
+				p.breaker.success()
+			} else {
+				p.breaker.release()
+			}
+			break
+		}
+		p.breaker.failure()
+	}
+
+	atomic.AddInt64(&p.metrics.failures, 1)
+	return nil, lastErr
+}
+
+// attempt performs a single HTTP call bounded by CallTimeout and the caller's context
+func (p *PaymentClient) attempt(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.CallTimeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := p.httpClient.Do(req)
+	atomic.AddInt64(&p.metrics.latencyTotal, int64(time.Since(start)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &PaymentError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return respBody, nil
+}
+
+// isRetryable reports whether a failed attempt may succeed if repeated
+func isRetryable(err error) bool {
+	var paymentErr *PaymentError
+	if errors.As(err, &paymentErr) {
+		return paymentErr.StatusCode >= 500 || paymentErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// sleepWithJitter waits for a random duration in [d/2, d) or until ctx is done
+func sleepWithJitter(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	wait := d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// circuitBreaker opens after a run of consecutive failures and lets a single
+// probe through once the cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	open      bool
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.open = false
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		b.open = true
+		b.openedAt = time.Now()
+	}
+	b.probing = false
+}
+
+// release ends a call that neither proved nor disproved the service's
+// health, letting another probe through if this one was a probe
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *circuitBreaker) state() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !b.open:
+		return "closed"
+	case b.probing || time.Since(b.openedAt) >= b.cooldown:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// ReservationConfig holds the cart stock reservation settings
+type ReservationConfig struct {
+	Window time.Duration // How long adding to cart holds stock; zero turns holds off
+}
+
+// Reservations is the active reservation configuration. Holds are off unless
+// CART_RESERVATION_MINUTES is set.
+var Reservations = loadReservationConfig()
+
+func loadReservationConfig() ReservationConfig {
+	var config ReservationConfig
+
+	if minutes, err := strconv.Atoi(os.Getenv("CART_RESERVATION_MINUTES")); err == nil && minutes > 0 {
+		config.Window = time.Duration(minutes) * time.Minute
+	}
+
+	return config
+}
+
+// Enabled reports whether adding to cart holds stock
+func (r ReservationConfig) Enabled() bool {
+	return r.Window > 0
+}
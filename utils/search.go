@@ -0,0 +1,469 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"goapi/models"
+)
+
+// SearchDocument is what the search index knows about a product
+type SearchDocument struct {
+	ID          int
+	Name        string
+	Description string
+	Price       models.Money        // Base currency, used for price buckets
+	Options     map[string][]string // Option values by lowercased option name
+	Categories  []string
+}
+
+// SearchQuery is a full-text query with optional facet filters. A document
+// matches a filter list when it has any of the listed values, and must match
+// every option listed.
+type SearchQuery struct {
+	Text        string
+	Options     map[string][]string // Values by lowercased option name
+	Categories  []string
+	PriceBucket string
+	Offset      int
+	Limit       int
+}
+
+// SearchHit is one matching product with its relevance score
+type SearchHit struct {
+	ID    int     `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// FacetCount is how many matches have a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets counts matches per option value, price bucket and category.
+// Each facet is counted with the other facets' filters applied but not its
+// own, so clients can show the alternatives to what is selected.
+type SearchFacets struct {
+	Options      map[string][]FacetCount `json:"options"`
+	PriceBuckets []FacetCount            `json:"price_buckets"`
+	Categories   []FacetCount            `json:"categories"`
+}
+
+// SearchResult is a page of hits, best first, with the total and facets
+type SearchResult struct {
+	Total  int
+	Hits   []SearchHit
+	Facets SearchFacets
+}
+
+// SearchIndex finds products by text. Swap Search for an external engine;
+// MemoryIndex keeps everything in process.
+type SearchIndex interface {
+	Index(doc SearchDocument) error
+	Remove(id int) error
+	Search(q SearchQuery) (SearchResult, error)
+	Suggest(prefix string, limit int) ([]string, error)
+}
+
+// PriceBucket is a base currency price range used as a search facet. A zero
+// Max means no upper bound.
+type PriceBucket struct {
+	Key string
+	Min models.Money
+	Max models.Money
+}
+
+// PriceBuckets are the price ranges search results are grouped into
+var PriceBuckets = []PriceBucket{
+	{"under-500", 0, models.MoneyFromFloat(500)},
+	{"500-1000", models.MoneyFromFloat(500), models.MoneyFromFloat(1000)},
+	{"1000-2500", models.MoneyFromFloat(1000), models.MoneyFromFloat(2500)},
+	{"2500-5000", models.MoneyFromFloat(2500), models.MoneyFromFloat(5000)},
+	{"5000-plus", models.MoneyFromFloat(5000), 0},
+}
+
+// priceBucket returns the key of the bucket a price falls in
+func priceBucket(price models.Money) string {
+	for _, bucket := range PriceBuckets {
+		if price >= bucket.Min && (bucket.Max == 0 || price < bucket.Max) {
+			return bucket.Key
+		}
+	}
+	return ""
+}
+
+// Search is the index the product endpoints use
+var Search SearchIndex = NewMemoryIndex()
+
+// termCounts is how often a term appears in a document's name and description
+type termCounts struct {
+	name        int
+	description int
+}
+
+// MemoryIndex is an in-process inverted index over product names and
+// descriptions. Matching tolerates typos and treats the last query word as
+// a prefix, so it also serves autocomplete.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[int]SearchDocument
+	postings map[string]map[int]termCounts
+}
+
+// NewMemoryIndex returns an empty index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[int]SearchDocument),
+		postings: make(map[string]map[int]termCounts),
+	}
+}
+
+// Index adds a document, replacing any earlier version of it
+func (m *MemoryIndex) Index(doc SearchDocument) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(doc.ID)
+	m.docs[doc.ID] = doc
+
+	for _, term := range tokenize(doc.Name) {
+		m.addPosting(term, doc.ID, termCounts{name: 1})
+	}
+	for _, term := range tokenize(doc.Description) {
+		m.addPosting(term, doc.ID, termCounts{description: 1})
+	}
+	return nil
+}
+
+func (m *MemoryIndex) addPosting(term string, id int, add termCounts) {
+	docs, ok := m.postings[term]
+	if !ok {
+		docs = make(map[int]termCounts)
+		m.postings[term] = docs
+	}
+	counts := docs[id]
+	counts.name += add.name
+	counts.description += add.description
+	docs[id] = counts
+}
+
+// Remove drops a document from the index
+func (m *MemoryIndex) Remove(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(id)
+	return nil
+}
+
+func (m *MemoryIndex) remove(id int) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	delete(m.docs, id)
+
+	for _, term := range tokenize(doc.Name + " " + doc.Description) {
+		if docs, ok := m.postings[term]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(m.postings, term)
+			}
+		}
+	}
+}
+
+// Search ranks documents containing every query word, allowing for typos.
+// Name matches count three times as much as description matches, and rarer
+// words count more than common ones.
+func (m *MemoryIndex) Search(q SearchQuery) (SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := SearchResult{Hits: []SearchHit{}}
+	scores := m.score(q.Text)
+
+	optionFilters := make(map[string]map[string]bool)
+	for name, values := range q.Options {
+		if filter := valueSet(values); filter != nil {
+			optionFilters[strings.ToLower(name)] = filter
+		}
+	}
+	categoryFilter := valueSet(q.Categories)
+	optionCounts := make(map[string]map[string]int)
+	categoryCounts := make(map[string]int)
+	bucketCounts := make(map[string]int)
+
+	for id, score := range scores {
+		doc := m.docs[id]
+		bucket := priceBucket(doc.Price)
+
+		// Note which option filters the document fails; an option's own
+		// facet is counted as long as no other option filter fails
+		var failedOption string
+		failedOptions := 0
+		for name, filter := range optionFilters {
+			if !hasAny(doc.Options[name], filter) {
+				failedOption = name
+				failedOptions++
+			}
+		}
+		inOptions := failedOptions == 0
+		inCategory := categoryFilter == nil || hasAny(doc.Categories, categoryFilter)
+		inBucket := q.PriceBucket == "" || q.PriceBucket == bucket
+
+		if inCategory && inBucket {
+			for name, values := range doc.Options {
+				if failedOptions == 0 || (failedOptions == 1 && failedOption == name) {
+					if optionCounts[name] == nil {
+						optionCounts[name] = make(map[string]int)
+					}
+					countValues(optionCounts[name], values)
+				}
+			}
+		}
+		if inOptions && inBucket {
+			countValues(categoryCounts, doc.Categories)
+		}
+		if inOptions && inCategory {
+			bucketCounts[bucket]++
+		}
+
+		if inOptions && inCategory && inBucket {
+			result.Hits = append(result.Hits, SearchHit{ID: id, Score: score})
+		}
+	}
+
+	sort.Slice(result.Hits, func(i, j int) bool {
+		if result.Hits[i].Score != result.Hits[j].Score {
+			return result.Hits[i].Score > result.Hits[j].Score
+		}
+		return result.Hits[i].ID < result.Hits[j].ID
+	})
+
+	result.Total = len(result.Hits)
+	if q.Offset > len(result.Hits) {
+		q.Offset = len(result.Hits)
+	}
+	result.Hits = result.Hits[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(result.Hits) {
+		result.Hits = result.Hits[:q.Limit]
+	}
+
+	result.Facets.Options = make(map[string][]FacetCount, len(optionCounts))
+	for name, counts := range optionCounts {
+		result.Facets.Options[name] = sortedCounts(counts)
+	}
+	result.Facets.Categories = sortedCounts(categoryCounts)
+	result.Facets.PriceBuckets = []FacetCount{}
+	for _, bucket := range PriceBuckets {
+		if count := bucketCounts[bucket.Key]; count > 0 {
+			result.Facets.PriceBuckets = append(result.Facets.PriceBuckets, FacetCount{bucket.Key, count})
+		}
+	}
+
+	return result, nil
+}
+
+// Suggest returns the names of the best matches for partly typed text
+func (m *MemoryIndex) Suggest(prefix string, limit int) ([]string, error) {
+	result, err := m.Search(SearchQuery{Text: prefix, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	suggestions := []string{}
+	seen := make(map[string]bool)
+	for _, hit := range result.Hits {
+		name := m.docs[hit.ID].Name
+		if !seen[name] {
+			seen[name] = true
+			suggestions = append(suggestions, name)
+		}
+	}
+	return suggestions, nil
+}
+
+// score returns the relevance of every document matching all words of text
+func (m *MemoryIndex) score(text string) map[int]float64 {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return map[int]float64{}
+	}
+
+	var scores map[int]float64
+	for i, word := range words {
+		isLast := i == len(words)-1
+
+		// Best match for this word in each document, so close spellings
+		// of the same word don't add up
+		best := make(map[int]float64)
+		for term, docs := range m.postings {
+			weight := matchWeight(word, term, isLast)
+			if weight == 0 {
+				continue
+			}
+
+			idf := 1 + float64(len(m.docs))/float64(len(docs)+1)
+			for id, counts := range docs {
+				fieldScore := 3*saturate(counts.name) + saturate(counts.description)
+				if s := weight * idf * fieldScore; s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for id := range scores {
+			if s, ok := best[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	// Names containing the whole query rank first
+	phrase := strings.Join(words, " ")
+	for id := range scores {
+		if strings.Contains(strings.ToLower(m.docs[id].Name), phrase) {
+			scores[id] *= 1.5
+		}
+	}
+
+	return scores
+}
+
+// matchWeight scores how well an indexed term matches a query word: exact
+// matches beat prefix matches (last word only), which beat typos
+func matchWeight(word, term string, prefix bool) float64 {
+	if word == term {
+		return 1
+	}
+	if prefix && strings.HasPrefix(term, word) {
+		return 0.8
+	}
+
+	maxEdits := 0
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		maxEdits = 2
+	case n >= 4:
+		maxEdits = 1
+	}
+	if maxEdits == 0 {
+		return 0
+	}
+
+	switch distance := editDistance(word, term, maxEdits); {
+	case distance > maxEdits:
+		return 0
+	case distance == 1:
+		return 0.6
+	default:
+		return 0.4
+	}
+}
+
+// editDistance is the Damerau-Levenshtein distance between a and b, or
+// limit+1 once it is known to exceed limit
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	if prev[len(rb)] > limit {
+		return limit + 1
+	}
+	return prev[len(rb)]
+}
+
+// tokenize lowercases text and splits it into words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+}
+
+// saturate dampens repeated words so stuffing a description doesn't win
+func saturate(count int) float64 {
+	return float64(count) / (float64(count) + 1)
+}
+
+func valueSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[strings.ToLower(value)] = true
+	}
+	return set
+}
+
+func hasAny(values []string, set map[string]bool) bool {
+	for _, value := range values {
+		if set[strings.ToLower(value)] {
+			return true
+		}
+	}
+	return false
+}
+
+func countValues(counts map[string]int, values []string) {
+	for _, value := range values {
+		counts[value]++
+	}
+}
+
+// sortedCounts lists facet counts, most common first
+func sortedCounts(counts map[string]int) []FacetCount {
+	facets := []FacetCount{}
+	for value, count := range counts {
+		facets = append(facets, FacetCount{value, count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
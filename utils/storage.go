@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps uploaded files such as product images. Swap Files for a
+// cloud bucket; LocalStorage writes to disk and is served by the API itself.
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// LocalStorage stores files under Dir and serves them below BaseURL
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+// Files is the storage uploads go to. Files live in UPLOAD_DIR (default
+// "uploads") and are served at UPLOAD_BASE_URL (default "/uploads").
+var Files Storage = loadLocalStorage()
+
+func loadLocalStorage() *LocalStorage {
+	storage := &LocalStorage{Dir: "uploads", BaseURL: "/uploads"}
+
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		storage.Dir = dir
+	}
+	if baseURL := os.Getenv("UPLOAD_BASE_URL"); baseURL != "" {
+		storage.BaseURL = strings.TrimRight(baseURL, "/")
+	}
+
+	return storage
+}
+
+// NewFileKey returns a fresh, unguessable key for a file under dir
+func NewFileKey(dir, ext string) string {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return strings.TrimRight(dir, "/") + "/" + hex.EncodeToString(random[:]) + ext
+}
+
+// path maps a key to a file under Dir, refusing keys that would escape it
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("invalid storage key")
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
+
+// Save writes the file, creating directories as needed
+func (s *LocalStorage) Save(ctx context.Context, key string, r io.Reader, contentType string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return err
+	}
+	return file.Close()
+}
+
+// Delete removes the file; a file that is already gone is not an error
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// URL returns where the file is served
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + "/" + strings.TrimLeft(key, "/")
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"math"
+	"os"
+	"strconv"
+
+	"goapi/models"
+)
+
+// TaxConfig holds the store-wide tax settings
+type TaxConfig struct {
+	DefaultRate      float64 // Percent applied to products without a tax class
+	PricesIncludeTax bool    // Whether catalog prices already contain tax
+}
+
+// Tax is the active tax configuration. Thai VAT is 7% and retail prices are quoted tax-inclusive.
+var Tax = loadTaxConfig()
+
+func loadTaxConfig() TaxConfig {
+	config := TaxConfig{DefaultRate: 7, PricesIncludeTax: true}
+
+	if rate, err := strconv.ParseFloat(os.Getenv("TAX_DEFAULT_RATE"), 64); err == nil && rate >= 0 {
+		config.DefaultRate = rate
+	}
+	if include, err := strconv.ParseBool(os.Getenv("TAX_PRICES_INCLUDE_TAX")); err == nil {
+		config.PricesIncludeTax = include
+	}
+
+	return config
+}
+
+// Split returns the tax on an amount priced at rate percent, rounded to the
+// nearest satang, along with the amount the customer pays for it
+func (t TaxConfig) Split(amount models.Money, rate float64) (tax, gross models.Money) {
+	if t.PricesIncludeTax {
+		tax = models.Money(math.Round(float64(amount) * rate / (100 + rate)))
+		return tax, amount
+	}
+	tax = amount.Percent(rate)
+	return tax, amount + tax
+}